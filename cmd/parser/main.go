@@ -1,24 +1,112 @@
 package main
 
 import (
-	"database/sql"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"log"
-	"math/rand"
+	"log/slog"
 	"net/http"
-	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
+	"github.com/gbandres98/wikidle2/internal/admin"
+	"github.com/gbandres98/wikidle2/internal/archive"
+	"github.com/gbandres98/wikidle2/internal/health"
+	"github.com/gbandres98/wikidle2/internal/logging"
 	"github.com/gbandres98/wikidle2/internal/parser"
+	"github.com/gbandres98/wikidle2/internal/parserflags"
+	"github.com/gbandres98/wikidle2/internal/server"
 	"github.com/gbandres98/wikidle2/internal/store"
-	"github.com/robfig/cron/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/urfave/cli/v2"
 )
 
-var dbUrl, dbDriver, cronString, addr, forceTitle string
+var dbUrl, dbDriver, addr, forceTitle string
 var force, show bool
+var forceRevision int64
+var categoryDepth, calendarDays int
+var scheduleDate, adminToken string
+var replacePin, onlyConflicts bool
+var bulkCount, bulkWorkers int
+var bulkRate float64
+var reparse bool
+var options = parserflags.New()
+var logFormat, logLevel string
+var timeouts = server.DefaultTimeouts()
+
+func logFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "log-format",
+			EnvVars:     []string{"WIKIDLE_LOG_FORMAT"},
+			Usage:       "Log format (text, json)",
+			Value:       logging.FormatText,
+			Destination: &logFormat,
+		},
+		&cli.StringFlag{
+			Name:        "log-level",
+			EnvVars:     []string{"WIKIDLE_LOG_LEVEL"},
+			Usage:       "Minimum level of the logged messages (debug, info, warn, error)",
+			Value:       "info",
+			Destination: &logLevel,
+		},
+	}
+}
+
+func setupLogging(c *cli.Context) error {
+	return logging.Setup(logFormat, logLevel)
+}
+
+func dbFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "db-driver",
+			EnvVars:     []string{"WIKIDLE_DATABASE_DRIVER"},
+			Usage:       "Database driver to use (sqlite3, postgres)",
+			Value:       "sqlite3",
+			Destination: &dbDriver,
+		},
+		&cli.StringFlag{
+			Name:        "db-dsn",
+			EnvVars:     []string{"WIKIDLE_DATABASE_DSN"},
+			Usage:       "Database connection string",
+			Value:       "file::memory:?cache=shared",
+			Destination: &dbUrl,
+		},
+	}
+}
+
+func depthFlag() cli.Flag {
+	return &cli.IntFlag{
+		Name:        "depth",
+		EnvVars:     []string{"WIKIDLE_CATEGORY_DEPTH"},
+		Usage:       "Levels of subcategories to queue articles from, 0 for only the category itself",
+		Value:       0,
+		Destination: &categoryDepth,
+	}
+}
+
+func dateFlag(required bool) cli.Flag {
+	usage := "First day, as 2006-01-02 or 20060102, today if empty"
+	if required {
+		usage = "Day, as 2006-01-02 or 20060102"
+	}
+
+	return &cli.StringFlag{
+		Name:        "date",
+		Aliases:     []string{"d"},
+		Usage:       usage,
+		Required:    required,
+		Destination: &scheduleDate,
+	}
+}
 
 func main() {
 	app := &cli.App{
@@ -28,31 +116,148 @@ func main() {
 		Commands: []*cli.Command{
 			{
 				Name:        "queue-category",
-				Description: "Queue all articles from a wikipedia category id",
+				Description: "Queue the articles of a wikipedia category and its subcategories, the featured articles category if none is given",
 				Args:        true,
-				ArgsUsage:   "Category id to process",
+				ArgsUsage:   " [category]",
 				Action:      queueCategory,
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:        "db-driver",
-						EnvVars:     []string{"WIKIDLE_DATABASE_DRIVER"},
-						Usage:       "Database driver to use (sqlite3, postgres)",
-						Value:       "sqlite3",
-						Destination: &dbDriver,
+				Before:      setupLogging,
+				Flags:       slices.Concat([]cli.Flag{depthFlag()}, dbFlags(), logFlags()),
+			},
+			{
+				Name:        "queue-good-articles",
+				Description: "Queue the articles of the good articles category",
+				Action:      queueGoodArticles,
+				Before:      setupLogging,
+				Flags:       slices.Concat([]cli.Flag{depthFlag()}, dbFlags(), logFlags()),
+			},
+			{
+				Name:        "queue-file",
+				Description: "Queue the articles of a file with a title per line, or of a .csv file with title and optional onDate columns",
+				Args:        true,
+				ArgsUsage:   " <file>",
+				Action:      queueFile,
+				Before:      setupLogging,
+				Flags:       slices.Concat(dbFlags(), logFlags()),
+			},
+			{
+				Name:        "schedule",
+				Description: "Pin an article to a day",
+				Args:        true,
+				ArgsUsage:   " <title>",
+				Action:      scheduleArticle,
+				Before:      setupLogging,
+				Flags: slices.Concat([]cli.Flag{
+					dateFlag(true),
+					&cli.BoolFlag{
+						Name:        "replace",
+						Usage:       "Replace the articles already pinned to the day",
+						Destination: &replacePin,
 					},
-					&cli.StringFlag{
-						Name:        "db-dsn",
-						EnvVars:     []string{"WIKIDLE_DATABASE_DSN"},
-						Usage:       "Database connection string",
-						Value:       "file::memory:?cache=shared",
-						Destination: &dbUrl,
+				}, dbFlags(), logFlags()),
+			},
+			{
+				Name:        "unschedule",
+				Description: "Remove the articles pinned to a day",
+				Action:      unscheduleDay,
+				Before:      setupLogging,
+				Flags:       slices.Concat([]cli.Flag{dateFlag(true)}, dbFlags(), logFlags()),
+			},
+			{
+				Name:        "calendar",
+				Description: "Show the articles of the upcoming days and the conflicts of the schedule",
+				Action:      showCalendar,
+				Before:      setupLogging,
+				Flags: slices.Concat([]cli.Flag{
+					dateFlag(false),
+					&cli.IntFlag{
+						Name:        "days",
+						Usage:       "Number of days to show",
+						Value:       14,
+						Destination: &calendarDays,
+					},
+					&cli.BoolFlag{
+						Name:        "conflicts",
+						Usage:       "Show every day with conflicting pins instead of the upcoming days",
+						Destination: &onlyConflicts,
 					},
-				},
+				}, dbFlags(), logFlags()),
+			},
+			{
+				Name:        "schedule-theme",
+				Description: "Pin random articles of a category to consecutive days",
+				Args:        true,
+				ArgsUsage:   " <category>",
+				Action:      scheduleTheme,
+				Before:      setupLogging,
+				Flags: slices.Concat([]cli.Flag{
+					dateFlag(true),
+					depthFlag(),
+					&cli.IntFlag{
+						Name:        "days",
+						Usage:       "Number of consecutive days to schedule",
+						Value:       7,
+						Destination: &calendarDays,
+					},
+				}, dbFlags(), logFlags()),
+			},
+			{
+				Name:        "export",
+				Description: "Export the articles, media, queue and games to an archive",
+				Args:        true,
+				ArgsUsage:   " <file>",
+				Action:      exportArchive,
+				Before:      setupLogging,
+				Flags:       slices.Concat(dbFlags(), logFlags()),
+			},
+			{
+				Name:        "import",
+				Description: "Import an archive written by export, replacing the articles, media and games with the same ids",
+				Args:        true,
+				ArgsUsage:   " <file>",
+				Action:      importArchive,
+				Before:      setupLogging,
+				Flags:       slices.Concat(dbFlags(), logFlags()),
+			},
+			{
+				Name:        "bulk",
+				Description: "Parse queued articles concurrently and store them as candidates with their quality, without making them live",
+				Action:      bulkParse,
+				Before:      setupLogging,
+				Flags: slices.Concat([]cli.Flag{
+					&cli.IntFlag{
+						Name:        "count",
+						Aliases:     []string{"n"},
+						Usage:       "Number of queued articles to parse",
+						Value:       50,
+						Destination: &bulkCount,
+					},
+					&cli.IntFlag{
+						Name:        "workers",
+						Aliases:     []string{"w"},
+						EnvVars:     []string{"WIKIDLE_BULK_WORKERS"},
+						Usage:       "Number of articles parsed at the same time",
+						Value:       4,
+						Destination: &bulkWorkers,
+					},
+					&cli.Float64Flag{
+						Name:        "rate",
+						EnvVars:     []string{"WIKIDLE_BULK_RATE"},
+						Usage:       "Maximum requests per second to Wikipedia, 0 for no limit",
+						Value:       5,
+						Destination: &bulkRate,
+					},
+					&cli.BoolFlag{
+						Name:        "reparse",
+						Usage:       "Parse articles that are already candidates again",
+						Destination: &reparse,
+					},
+				}, dbFlags(), options.ParserFlags(), logFlags()),
 			},
 			{
 				Name:        "force",
 				Description: "Replace current article with a new one",
-				Flags: []cli.Flag{
+				Before:      setupLogging,
+				Flags: slices.Concat([]cli.Flag{
 					&cli.StringFlag{
 						Name:        "title",
 						Aliases:     []string{"t"},
@@ -60,6 +265,12 @@ func main() {
 						Value:       "",
 						Destination: &forceTitle,
 					},
+					&cli.Int64Flag{
+						Name:        "revision",
+						Aliases:     []string{"r"},
+						Usage:       "Wikipedia revision id to parse, the title can be omitted",
+						Destination: &forceRevision,
+					},
 					&cli.BoolFlag{
 						Name:        "show",
 						Aliases:     []string{"s"},
@@ -67,46 +278,11 @@ func main() {
 						Value:       false,
 						Destination: &show,
 					},
-					&cli.StringFlag{
-						Name:        "db-driver",
-						EnvVars:     []string{"WIKIDLE_DATABASE_DRIVER"},
-						Usage:       "Database driver to use (sqlite3, postgres)",
-						Value:       "sqlite3",
-						Destination: &dbDriver,
-					},
-					&cli.StringFlag{
-						Name:        "db-dsn",
-						EnvVars:     []string{"WIKIDLE_DATABASE_DSN"},
-						Usage:       "Database connection string",
-						Value:       "file::memory:?cache=shared",
-						Destination: &dbUrl,
-					},
-				},
+				}, dbFlags(), options.ParserFlags(), logFlags()),
 				Action: replace,
 			},
 		},
-		Flags: []cli.Flag{
-			&cli.StringFlag{
-				Name:        "db-driver",
-				EnvVars:     []string{"WIKIDLE_DATABASE_DRIVER"},
-				Usage:       "Database driver to use (sqlite3, postgres)",
-				Value:       "sqlite3",
-				Destination: &dbDriver,
-			},
-			&cli.StringFlag{
-				Name:        "db-dsn",
-				EnvVars:     []string{"WIKIDLE_DATABASE_DSN"},
-				Usage:       "Database connection string",
-				Value:       "file::memory:?cache=shared",
-				Destination: &dbUrl,
-			},
-			&cli.StringFlag{
-				Name:        "cron",
-				EnvVars:     []string{"WIKIDLE_PARSER_CRON"},
-				Usage:       "Cron to run the article parsing job",
-				Value:       "0 0 * * *",
-				Destination: &cronString,
-			},
+		Flags: slices.Concat(dbFlags(), []cli.Flag{
 			&cli.StringFlag{
 				Name:        "listen-addr",
 				EnvVars:     []string{"WIKIDLE_LISTEN_ADDRESS"},
@@ -114,178 +290,477 @@ func main() {
 				Value:       "0.0.0.0:8080",
 				Destination: &addr,
 			},
-		},
+			&cli.StringFlag{
+				Name:        "admin-token",
+				EnvVars:     []string{"WIKIDLE_ADMIN_TOKEN"},
+				Usage:       "Bearer token of the admin endpoints, which are disabled if empty",
+				Destination: &adminToken,
+			},
+			&cli.DurationFlag{
+				Name:        "shutdown-timeout",
+				EnvVars:     []string{"WIKIDLE_SHUTDOWN_TIMEOUT"},
+				Usage:       "Maximum duration to wait for running jobs and requests when stopping",
+				Value:       timeouts.Shutdown,
+				Destination: &timeouts.Shutdown,
+			},
+		}, options.SchedulerFlags(), options.ParserFlags(), logFlags()),
+		Before: setupLogging,
 	}
 
 	if err := app.Run(os.Args); err != nil {
-		log.Panic(err)
+		slog.Error("Parser failed", "error", err)
+		os.Exit(1)
 	}
 }
 
 func start(c *cli.Context) error {
-	ctx := c.Context
+	ctx, stop := signal.NotifyContext(c.Context, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	db, err := store.NewDB(ctx, dbDriver, dbUrl, true)
 	if err != nil {
 		return err
 	}
 
-	p := parser.New(db)
-
-	_, err = db.GetArticleByID(ctx, parser.GetGameID(time.Now()))
-	if err != nil && err != sql.ErrNoRows {
-		return fmt.Errorf("Error getting retrieving article from db: %v", err)
+	err = parser.MigrateArticleStorage(ctx, db.Queries)
+	if err != nil {
+		return err
 	}
 
-	if err == sql.ErrNoRows {
-		log.Println("No article in db, parsing article from queue")
-		articleTitle, err := p.GetArticleTitleFromQueue(ctx)
-		if err != nil {
-			return err
-		}
+	store.RegisterMetrics(prometheus.DefaultRegisterer)
+	parser.RegisterMetrics(prometheus.DefaultRegisterer, db.Queries)
 
-		err = p.ParseArticle(ctx, articleTitle)
+	scheduler := options.NewScheduler(db)
+
+	// The server starts while the article is parsed, readiness fails until it's
+	// stored. A failed startup parse has already been alerted, the server keeps
+	// running so the next scheduled run can retry.
+	startupParse := make(chan struct{})
+	go func() {
+		defer close(startupParse)
+
+		err := scheduler.RunDaily(ctx)
 		if err != nil {
-			return err
+			slog.ErrorContext(ctx, "Startup article parsing failed", "error", err)
 		}
+	}()
+
+	err = scheduler.Start(ctx)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	health.Register(mux, append(db.HealthChecks(), parser.ArticleOfTheDayCheck(db.Queries))...)
+	mux.Handle("GET /metrics", promhttp.Handler())
+
+	if adminToken != "" {
+		admin.New(db, adminToken).RegisterHandlers(mux)
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Run(ctx, addr, logging.Middleware(mux), timeouts)
+	}()
+
+	select {
+	case err = <-serverErr:
+		stop()
+	case <-ctx.Done():
+		err = <-serverErr
+	}
+
+	slog.InfoContext(ctx, "Waiting for running parsing jobs")
+
+	waitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeouts.Shutdown)
+	defer cancel()
+
+	select {
+	case <-startupParse:
+	case <-waitCtx.Done():
 	}
 
-	cr := cron.New()
+	select {
+	case <-scheduler.Stop().Done():
+	case <-waitCtx.Done():
+		slog.WarnContext(ctx, "Parsing jobs still running after the shutdown timeout")
+	}
+
+	return errors.Join(err, db.Close())
+}
+
+func replace(c *cli.Context) error {
+	ctx := c.Context
 
-	_, err = cr.AddFunc(cronString, func() {
-		log.Printf("Running article parsing job at %v\n", time.Now())
+	db, err := store.NewDB(ctx, dbDriver, dbUrl, true)
+	if err != nil {
+		return err
+	}
 
-		gameID := parser.GetGameID(time.Now())
+	err = parser.MigrateArticleStorage(ctx, db.Queries)
+	if err != nil {
+		return err
+	}
 
-		_, err := db.GetArticleByID(ctx, gameID)
-		if err != nil && err != sql.ErrNoRows {
-			panic(fmt.Errorf("Error getting retrieving article from db: %v", err))
+	p := options.NewParser(db)
+
+	if forceTitle == "" && forceRevision == 0 {
+		forceTitle, err = p.ParseNextArticle(ctx)
+		if err != nil {
+			return err
 		}
 
-		if err == nil {
-			log.Printf("Article already exists in db for game id %s\n", gameID)
-			return
+		if show {
+			slog.InfoContext(ctx, "Parsed article", "title", forceTitle)
 		}
+		return nil
+	}
+
+	if show {
+		slog.InfoContext(ctx, "Parsing article", "title", forceTitle, "revision", forceRevision)
+	}
+	return p.ParseArticle(ctx, forceTitle, forceRevision)
+}
+
+func bulkParse(c *cli.Context) error {
+	ctx := c.Context
+
+	if bulkCount < 1 {
+		return errors.New("count must be at least 1")
+	}
 
-		log.Printf("No article in db for game id %s, parsing article from queue\n", gameID)
-		articleTitle, err := p.GetArticleTitleFromQueue(ctx)
+	db, err := store.NewDB(ctx, dbDriver, dbUrl, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	parsed := map[string]bool{}
+	if !reparse {
+		candidates, err := db.GetArticleCandidateTitles(ctx)
 		if err != nil {
-			panic(err)
+			return fmt.Errorf("failed to get candidate articles: %w", err)
 		}
 
-		err = p.ParseArticle(ctx, articleTitle)
-		if err != nil {
-			panic(err)
+		for _, title := range candidates {
+			parsed[title] = true
 		}
+	}
+
+	// Articles are taken in the order they were queued
+	titles := []string{}
+	err = db.InSnapshot(ctx, func(s *store.Snapshot) error {
+		return s.EachQueueArticle(ctx, func(article store.ListQueueArticlesRow) error {
+			if len(titles) < bulkCount && !parsed[article.Title] {
+				parsed[article.Title] = true
+				titles = append(titles, article.Title)
+			}
+
+			return nil
+		})
 	})
+	if err != nil {
+		return fmt.Errorf("failed to list queued articles: %w", err)
+	}
+
+	if len(titles) == 0 {
+		slog.InfoContext(ctx, "No queued articles to parse")
+		return nil
+	}
+
+	previousRate := parser.SetRateLimit(bulkRate)
+	defer parser.SetRateLimit(previousRate)
+
+	slog.InfoContext(ctx, "Parsing candidate articles", "count", len(titles), "workers", bulkWorkers, "rate", bulkRate)
+
+	// Failures to save candidates are reported after the results
+	candidates, saveErr := options.NewParser(db).ParseCandidates(ctx, titles, bulkWorkers)
+	if ctx.Err() != nil {
+		return saveErr
+	}
+
+	w := tabwriter.NewWriter(c.App.Writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TITLE\tREVISION\tSTATUS\tWORDS\tERROR")
+	for _, candidate := range candidates {
+		words, reason := "", ""
+		if candidate.Quality != nil {
+			words = fmt.Sprint(candidate.Quality.WordCount)
+		}
+		if candidate.Err != nil {
+			reason = candidate.Err.Error()
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", candidate.Title, candidate.Revision, candidate.Status, words, reason)
+	}
+
+	return errors.Join(w.Flush(), saveErr)
+}
+
+func queueCategory(c *cli.Context) error {
+	category := c.Args().First()
+	if category == "" {
+		category = parser.FeaturedArticlesCategory
+	}
+
+	return queueFromCategory(c.Context, category)
+}
+
+func queueGoodArticles(c *cli.Context) error {
+	return queueFromCategory(c.Context, parser.GoodArticlesCategory)
+}
+
+func queueFromCategory(ctx context.Context, category string) error {
+	db, err := store.NewDB(ctx, dbDriver, dbUrl, true)
 	if err != nil {
 		return err
 	}
+	defer db.Close()
 
-	go func() {
-		mux := http.DefaultServeMux
-		mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {})
+	titles, err := parser.CategoryArticles(ctx, category, categoryDepth)
+	if err != nil {
+		return err
+	}
 
-		err := http.ListenAndServe(addr, mux)
-		log.Println(err)
-	}()
+	slog.InfoContext(ctx, "Got category articles", "category", category, "depth", categoryDepth, "count", len(titles))
 
-	log.Printf("Started cron schedule with %s\n", cronString)
-	cr.Run()
+	entries := make([]parser.QueueEntry, len(titles))
+	for i, title := range titles {
+		entries[i] = parser.QueueEntry{Title: title}
+	}
 
-	return nil
+	_, err = parser.EnqueueArticles(ctx, db, entries)
+	return err
 }
 
-func replace(c *cli.Context) error {
+func queueFile(c *cli.Context) error {
 	ctx := c.Context
 
+	path := c.Args().First()
+	if path == "" {
+		return errors.New("missing file path")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open queue file: %w", err)
+	}
+	defer file.Close()
+
+	entries, err := parser.ReadQueueFile(file, strings.EqualFold(filepath.Ext(path), ".csv"))
+	if err != nil {
+		return fmt.Errorf("failed to read queue file: %w", err)
+	}
+
 	db, err := store.NewDB(ctx, dbDriver, dbUrl, true)
 	if err != nil {
 		return err
 	}
+	defer db.Close()
 
-	p := parser.New(db)
+	_, err = parser.EnqueueArticles(ctx, db, entries)
+	return err
+}
 
-	if forceTitle == "" {
-		forceTitle, err = p.GetArticleTitleFromQueue(ctx)
-		if err != nil {
-			return err
-		}
+func exportArchive(c *cli.Context) error {
+	ctx := c.Context
+
+	path := c.Args().First()
+	if path == "" {
+		return errors.New("missing archive path")
 	}
 
-	if show {
-		log.Println(forceTitle)
+	db, err := store.NewDB(ctx, dbDriver, dbUrl, true)
+	if err != nil {
+		return err
 	}
-	return p.ParseArticle(ctx, forceTitle)
+	defer db.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	manifest, err := archive.Export(ctx, db, file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Exported archive", "path", path, "counts", manifest.Counts)
+	return nil
 }
 
-func queueCategory(c *cli.Context) error {
+func importArchive(c *cli.Context) error {
+	ctx := c.Context
+
+	path := c.Args().First()
+	if path == "" {
+		return errors.New("missing archive path")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	db, err := store.NewDB(ctx, dbDriver, dbUrl, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	manifest, err := archive.Import(ctx, db, file, info.Size())
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Imported archive", "path", path, "version", manifest.Version, "created_at", manifest.CreatedAt, "counts", manifest.Counts)
+
+	// Articles imported in the legacy format are rewritten like on startup
+	return parser.MigrateArticleStorage(ctx, db.Queries)
+}
+
+func scheduleDay() (time.Time, error) {
+	if scheduleDate == "" {
+		return time.Now(), nil
+	}
+
+	return parser.ParseDate(scheduleDate)
+}
+
+func scheduleArticle(c *cli.Context) error {
 	ctx := c.Context
 
+	title := strings.TrimSpace(c.Args().First())
+	if title == "" {
+		return errors.New("missing article title")
+	}
+
+	date, err := scheduleDay()
+	if err != nil {
+		return err
+	}
+
+	title, err = parser.ResolveTitle(ctx, title)
+	if err != nil {
+		return err
+	}
+
 	db, err := store.NewDB(ctx, dbDriver, dbUrl, true)
 	if err != nil {
 		return err
 	}
+	defer db.Close()
 
-	type apiResponse struct {
-		Continue struct {
-			Code string `json:"cmcontinue"`
-		} `json:"continue"`
-		Query struct {
-			Pages []struct {
-				Title string `json:"title"`
-			} `json:"categorymembers"`
-		} `json:"query"`
+	err = db.InTx(ctx, func(q *store.Queries) error {
+		return parser.ScheduleArticle(ctx, q, title, date, replacePin)
+	})
+	if err != nil {
+		return err
 	}
 
-	req, err := url.Parse("https://es.wikipedia.org/w/api.php?format=json&formatversion=2&origin=*&action=query&list=categorymembers&uselang=content&cmtitle=" + url.QueryEscape("Categoría:Wikipedia:Artículos destacados") + "&cmlimit=500")
+	slog.InfoContext(ctx, "Scheduled article", "title", title, "game_id", parser.GetGameID(date))
+	return nil
+}
+
+func unscheduleDay(c *cli.Context) error {
+	ctx := c.Context
+
+	date, err := scheduleDay()
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	pages := []string{}
-	query := 1
+	db, err := store.NewDB(ctx, dbDriver, dbUrl, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
 
-	for {
-		log.Printf("query %d", query)
-		query++
+	count, err := parser.UnscheduleDay(ctx, db.Queries, date)
+	if err != nil {
+		return err
+	}
 
-		var response apiResponse
+	slog.InfoContext(ctx, "Unscheduled day", "game_id", parser.GetGameID(date), "count", count)
+	return nil
+}
 
-		res, err := http.Get(req.String())
-		if err != nil {
-			panic(err)
-		}
+func showCalendar(c *cli.Context) error {
+	ctx := c.Context
 
-		err = json.NewDecoder(res.Body).Decode(&response)
-		if err != nil {
-			panic(err)
-		}
+	date, err := scheduleDay()
+	if err != nil {
+		return err
+	}
 
-		for _, page := range response.Query.Pages {
-			pages = append(pages, page.Title)
-		}
+	db, err := store.NewDB(ctx, dbDriver, dbUrl, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
 
-		if response.Continue.Code == "" {
-			break
-		}
+	var days []parser.CalendarDay
+	if onlyConflicts {
+		days, err = parser.ScheduleConflicts(ctx, db.Queries)
+	} else {
+		days, err = parser.Calendar(ctx, db.Queries, date, calendarDays)
+	}
+	if err != nil {
+		return err
+	}
 
-		query := req.Query()
-		query.Set("cmcontinue", response.Continue.Code)
+	w := tabwriter.NewWriter(c.App.Writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DAY\tARTICLE\tPINNED\tCONFLICTS")
+	for _, day := range days {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", day.GameID, day.Article, strings.Join(day.Pinned, ", "), strings.Join(day.Conflicts, "; "))
+	}
+
+	return w.Flush()
+}
+
+func scheduleTheme(c *cli.Context) error {
+	ctx := c.Context
 
-		req.RawQuery = query.Encode()
+	category := c.Args().First()
+	if category == "" {
+		return errors.New("missing category")
 	}
 
-	log.Printf("got %d page names", len(pages))
+	date, err := scheduleDay()
+	if err != nil {
+		return err
+	}
 
-	rand.Shuffle(len(pages), func(i, j int) { pages[i], pages[j] = pages[j], pages[i] })
+	db, err := store.NewDB(ctx, dbDriver, dbUrl, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
 
-	for i, page := range pages {
-		log.Printf("storing page %d of %d", i, len(pages))
-		err = db.AddArticleToQueue(ctx, page)
-		if err != nil {
-			panic(err)
-		}
+	titles, err := parser.CategoryArticles(ctx, category, categoryDepth)
+	if err != nil {
+		return err
+	}
+
+	entries, err := parser.ScheduleTheme(ctx, db, titles, date, calendarDays)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		slog.InfoContext(ctx, "Scheduled article", "title", entry.Title, "game_id", entry.OnDate)
 	}
 
 	return nil
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Responses smaller than this are not worth compressing
+const minCompressSize = 512
+
+var compressibleTypes = []string{
+	"text/",
+	"application/javascript",
+	"application/json",
+	"image/svg+xml",
+}
+
+var gzipPool = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+
+var brotliPool = sync.Pool{New: func() any { return brotli.NewWriterLevel(io.Discard, 5) }}
+
+// compressMiddleware compresses text responses with brotli or gzip, depending
+// on what the client accepts
+func compressMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// acceptedEncoding returns the preferred encoding of an Accept-Encoding header
+// among the supported ones
+func acceptedEncoding(header string) string {
+	accepted := make(map[string]bool)
+
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if value, err := strconv.ParseFloat(q, 64); err == nil && value == 0 {
+				continue
+			}
+		}
+
+		accepted[strings.ToLower(name)] = true
+	}
+
+	switch {
+	case accepted["br"]:
+		return "br"
+	case accepted["gzip"]:
+		return "gzip"
+	}
+
+	return ""
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	writer      io.WriteCloser
+	wroteHeader bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	if cw.shouldCompress(status) {
+		header := cw.Header()
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		header.Del("Accept-Ranges")
+
+		// The compressed body is not byte for byte the same as the original
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+
+		switch cw.encoding {
+		case "br":
+			bw := brotliPool.Get().(*brotli.Writer)
+			bw.Reset(cw.ResponseWriter)
+			cw.writer = bw
+		case "gzip":
+			gw := gzipPool.Get().(*gzip.Writer)
+			gw.Reset(cw.ResponseWriter)
+			cw.writer = gw
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressWriter) shouldCompress(status int) bool {
+	header := cw.Header()
+
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+
+	if length, err := strconv.Atoi(header.Get("Content-Length")); err == nil && length < minCompressSize {
+		return false
+	}
+
+	contentType := header.Get("Content-Type")
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.writer == nil {
+		return cw.ResponseWriter.Write(b)
+	}
+
+	return cw.writer.Write(b)
+}
+
+func (cw *compressWriter) Flush() {
+	if flusher, ok := cw.writer.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (cw *compressWriter) Close() error {
+	if cw.writer == nil {
+		return nil
+	}
+
+	err := cw.writer.Close()
+
+	switch writer := cw.writer.(type) {
+	case *brotli.Writer:
+		brotliPool.Put(writer)
+	case *gzip.Writer:
+		gzipPool.Put(writer)
+	}
+	cw.writer = nil
+
+	return err
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
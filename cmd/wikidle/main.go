@@ -1,26 +1,42 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"syscall"
 
+	"github.com/gbandres98/wikidle2/internal/admin"
 	"github.com/gbandres98/wikidle2/internal/game"
+	"github.com/gbandres98/wikidle2/internal/health"
+	"github.com/gbandres98/wikidle2/internal/logging"
+	"github.com/gbandres98/wikidle2/internal/parser"
+	"github.com/gbandres98/wikidle2/internal/parserflags"
+	"github.com/gbandres98/wikidle2/internal/server"
 	"github.com/gbandres98/wikidle2/internal/static"
 	"github.com/gbandres98/wikidle2/internal/store"
 	_ "github.com/joho/godotenv/autoload"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/urfave/cli/v2"
 )
 
 var dbUrl, dbDriver, addr, baseAddress string
-var articleCache bool
+var articleCache, withParser bool
+var titlePenalty int
+var logFormat, logLevel, adminToken string
+var timeouts = server.DefaultTimeouts()
+var parserOptions = parserflags.New()
 
 func main() {
 	app := &cli.App{
 		Name:   "wikidle3-api",
 		Usage:  "API server for wikidle3",
 		Action: start,
-		Flags: []cli.Flag{
+		Flags: slices.Concat([]cli.Flag{
 			&cli.StringFlag{
 				Name:        "db-driver",
 				EnvVars:     []string{"WIKIDLE_DATABASE_DRIVER"},
@@ -56,30 +72,163 @@ func main() {
 				Value:       true,
 				Destination: &articleCache,
 			},
+			&cli.IntFlag{
+				Name:        "title-guess-penalty",
+				EnvVars:     []string{"WIKIDLE_TITLE_GUESS_PENALTY"},
+				Usage:       "Attempts added for each wrong full title guess",
+				Value:       5,
+				Destination: &titlePenalty,
+			},
+			&cli.DurationFlag{
+				Name:        "read-timeout",
+				EnvVars:     []string{"WIKIDLE_READ_TIMEOUT"},
+				Usage:       "Maximum duration for reading a request",
+				Value:       timeouts.Read,
+				Destination: &timeouts.Read,
+			},
+			&cli.DurationFlag{
+				Name:        "write-timeout",
+				EnvVars:     []string{"WIKIDLE_WRITE_TIMEOUT"},
+				Usage:       "Maximum duration for writing a response",
+				Value:       timeouts.Write,
+				Destination: &timeouts.Write,
+			},
+			&cli.DurationFlag{
+				Name:        "idle-timeout",
+				EnvVars:     []string{"WIKIDLE_IDLE_TIMEOUT"},
+				Usage:       "Maximum duration to keep idle connections open",
+				Value:       timeouts.Idle,
+				Destination: &timeouts.Idle,
+			},
+			&cli.DurationFlag{
+				Name:        "shutdown-timeout",
+				EnvVars:     []string{"WIKIDLE_SHUTDOWN_TIMEOUT"},
+				Usage:       "Maximum duration to wait for in-flight work when stopping",
+				Value:       timeouts.Shutdown,
+				Destination: &timeouts.Shutdown,
+			},
+			&cli.StringFlag{
+				Name:        "log-format",
+				EnvVars:     []string{"WIKIDLE_LOG_FORMAT"},
+				Usage:       "Log format (text, json)",
+				Value:       logging.FormatText,
+				Destination: &logFormat,
+			},
+			&cli.StringFlag{
+				Name:        "log-level",
+				EnvVars:     []string{"WIKIDLE_LOG_LEVEL"},
+				Usage:       "Minimum level of the logged messages (debug, info, warn, error)",
+				Value:       "info",
+				Destination: &logLevel,
+			},
+			&cli.StringFlag{
+				Name:        "admin-token",
+				EnvVars:     []string{"WIKIDLE_ADMIN_TOKEN"},
+				Usage:       "Bearer token of the admin endpoints, which are disabled if empty",
+				Destination: &adminToken,
+			},
+			&cli.BoolFlag{
+				Name:        "with-parser",
+				EnvVars:     []string{"WIKIDLE_WITH_PARSER"},
+				Usage:       "Run the article parsing job in this process instead of a separate parser",
+				Destination: &withParser,
+			},
+		}, parserOptions.SchedulerFlags(), parserOptions.ParserFlags()),
+		Before: func(c *cli.Context) error {
+			return logging.Setup(logFormat, logLevel)
 		},
 	}
 
 	if err := app.Run(os.Args); err != nil {
-		log.Panic(err)
+		slog.Error("Server failed", "error", err)
+		os.Exit(1)
 	}
 }
 
 func start(c *cli.Context) error {
-	ctx := c.Context
+	ctx, stop := signal.NotifyContext(c.Context, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	db, err := store.NewDB(ctx, dbDriver, dbUrl, true)
 	if err != nil {
 		return err
 	}
 
+	err = parser.MigrateArticleStorage(ctx, db.Queries)
+	if err != nil {
+		return err
+	}
+
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {})
-	mux.Handle("GET /{file...}", http.FileServerFS(static.FS()))
+	health.Register(mux, append(db.HealthChecks(), parser.ArticleOfTheDayCheck(db.Queries))...)
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.Handle("GET /{file...}", static.Handler())
+
+	store.RegisterMetrics(prometheus.DefaultRegisterer)
+	game.RegisterMetrics(prometheus.DefaultRegisterer)
 
-	game := game.New(db, baseAddress, articleCache)
+	game := game.New(db.Queries, baseAddress, articleCache, titlePenalty)
 	game.RegisterHandlers(mux)
 
-	log.Printf("Server started at %s\n", addr)
-	return http.ListenAndServe(addr, mux)
+	if adminToken != "" {
+		admin.New(db, adminToken).RegisterHandlers(mux)
+	}
+
+	var scheduler *parser.Scheduler
+	var startupParse chan struct{}
+	if withParser {
+		parser.RegisterMetrics(prometheus.DefaultRegisterer, db.Queries)
+
+		scheduler = parserOptions.NewScheduler(db)
+		startupParse = make(chan struct{})
+
+		// The server starts while the article is parsed, readiness fails
+		// until it's stored
+		go func() {
+			defer close(startupParse)
+
+			err := scheduler.RunDaily(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "Startup article parsing failed", "error", err)
+			}
+		}()
+
+		err = scheduler.Start(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	slog.InfoContext(ctx, "Server started", "addr", addr)
+	err = server.Run(ctx, addr, logging.Middleware(compressMiddleware(mux)), timeouts)
+	if err != nil {
+		return err
+	}
+
+	waitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeouts.Shutdown)
+	defer cancel()
+
+	err = game.Wait(waitCtx)
+	if err != nil {
+		return err
+	}
+
+	if scheduler != nil {
+		slog.InfoContext(ctx, "Waiting for running parsing jobs")
+
+		select {
+		case <-startupParse:
+		case <-waitCtx.Done():
+		}
+
+		select {
+		case <-scheduler.Stop().Done():
+		case <-waitCtx.Done():
+			slog.WarnContext(ctx, "Parsing jobs still running after the shutdown timeout")
+		}
+	}
+
+	slog.InfoContext(ctx, "Server stopped")
+	return db.Close()
 }
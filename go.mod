@@ -4,28 +4,37 @@ go 1.22.0
 
 require (
 	github.com/PuerkitoBio/goquery v1.9.0
+	github.com/andybalholm/brotli v1.0.6
+	github.com/dlclark/regexp2 v1.11.0
 	github.com/google/uuid v1.6.0
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
-	github.com/robfig/cron v1.2.0
+	github.com/mattn/go-sqlite3 v1.14.31
+	github.com/prometheus/client_golang v1.22.0
+	github.com/robfig/cron/v3 v3.0.0
 	github.com/urfave/cli/v2 v2.27.2
 )
 
 require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/cpuguy83/go-md2man/v2 v2.0.4 // indirect
-	github.com/mattn/go-sqlite3 v1.14.31 // indirect
 	github.com/mfridman/interpolate v0.0.2 // indirect
-	github.com/robfig/cron/v3 v3.0.0 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.62.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/sethvargo/go-retry v0.2.4 // indirect
 	github.com/xrash/smetrics v0.0.0-20240312152122-5f08fbb34913 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	golang.org/x/sync v0.7.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+	google.golang.org/protobuf v1.36.5 // indirect
 )
 
 require (
 	github.com/andybalholm/cascadia v1.3.2 // indirect
-	github.com/dlclark/regexp2 v1.11.0
 	github.com/pressly/goose/v3 v3.21.1
-	golang.org/x/net v0.23.0 // indirect
+	golang.org/x/net v0.33.0
 )
@@ -0,0 +1,179 @@
+// Package admin serves the endpoints used to manage the article schedule
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/wikidle2/internal/parser"
+	"github.com/gbandres98/wikidle2/internal/store"
+)
+
+// Days shown by the calendar when none are requested
+const defaultCalendarDays = 14
+
+type Api struct {
+	db    *store.DB
+	token string
+}
+
+func New(db *store.DB, token string) *Api {
+	return &Api{
+		db:    db,
+		token: token,
+	}
+}
+
+// RegisterHandlers registers the admin endpoints, which require the token as
+// a bearer token
+func (a *Api) RegisterHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("GET /admin/calendar", a.authMiddleware(a.handleCalendar))
+
+	mux.HandleFunc("GET /admin/schedule/conflicts", a.authMiddleware(a.handleConflicts))
+
+	mux.HandleFunc("PUT /admin/schedule/{date}", a.authMiddleware(a.handleSchedule))
+
+	mux.HandleFunc("DELETE /admin/schedule/{date}", a.authMiddleware(a.handleUnschedule))
+}
+
+func (a *Api) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func (a *Api) handleCalendar(w http.ResponseWriter, r *http.Request) {
+	from := time.Now()
+	if value := r.URL.Query().Get("from"); value != "" {
+		var err error
+		from, err = parser.ParseDate(value)
+		if err != nil {
+			writeError(w, r, err, http.StatusBadRequest, "invalid from date")
+			return
+		}
+	}
+
+	days := defaultCalendarDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		var err error
+		days, err = strconv.Atoi(value)
+		if err != nil || days < 1 || days > 366 {
+			writeError(w, r, err, http.StatusBadRequest, "days must be between 1 and 366")
+			return
+		}
+	}
+
+	calendar, err := parser.Calendar(r.Context(), a.db.Queries, from, days)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError, "failed to get calendar")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, calendar)
+}
+
+func (a *Api) handleConflicts(w http.ResponseWriter, r *http.Request) {
+	conflicts, err := parser.ScheduleConflicts(r.Context(), a.db.Queries)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError, "failed to get schedule conflicts")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, conflicts)
+}
+
+func (a *Api) handleSchedule(w http.ResponseWriter, r *http.Request) {
+	date, err := parser.ParseDate(r.PathValue("date"))
+	if err != nil {
+		writeError(w, r, err, http.StatusBadRequest, "invalid date")
+		return
+	}
+
+	title := strings.TrimSpace(r.FormValue("title"))
+	if title == "" {
+		writeError(w, r, nil, http.StatusBadRequest, "missing title")
+		return
+	}
+
+	replace, _ := strconv.ParseBool(r.FormValue("replace"))
+
+	title, err = parser.ResolveTitle(r.Context(), title)
+	if errors.Is(err, parser.ErrInvalidTitle) {
+		writeError(w, r, err, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, r, err, http.StatusBadGateway, "failed to resolve title")
+		return
+	}
+
+	err = a.db.InTx(r.Context(), func(q *store.Queries) error {
+		return parser.ScheduleArticle(r.Context(), q, title, date, replace)
+	})
+	if errors.Is(err, parser.ErrScheduleConflict) {
+		writeError(w, r, err, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError, "failed to schedule article")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Scheduled article", "title", title, "game_id", parser.GetGameID(date))
+
+	writeJSON(w, r, http.StatusOK, parser.QueueEntry{Title: title, OnDate: parser.GetGameID(date)})
+}
+
+func (a *Api) handleUnschedule(w http.ResponseWriter, r *http.Request) {
+	date, err := parser.ParseDate(r.PathValue("date"))
+	if err != nil {
+		writeError(w, r, err, http.StatusBadRequest, "invalid date")
+		return
+	}
+
+	count, err := parser.UnscheduleDay(r.Context(), a.db.Queries, date)
+	if err != nil {
+		writeError(w, r, err, http.StatusInternalServerError, "failed to unschedule day")
+		return
+	}
+
+	if count == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Unscheduled day", "game_id", parser.GetGameID(date), "count", count)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to write response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error, code int, message string) {
+	if code >= http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), message, "error", err, "status", code)
+	}
+
+	http.Error(w, message, code)
+}
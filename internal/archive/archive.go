@@ -0,0 +1,344 @@
+// Package archive exports the database to a portable archive and imports it
+// back, regardless of the database driver on either side
+package archive
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gbandres98/wikidle2/internal/store"
+)
+
+// Version of the archive layout, increased on incompatible changes
+const Version = 1
+
+const manifestFile = "manifest.json"
+
+// Tables in the archive, each stored in a JSONL file with its name
+const (
+	articleTable = "article"
+	queueTable   = "article_queue"
+	gameTable    = "game"
+	mediaTable   = "media"
+)
+
+// Manifest describes the contents of an archive
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	// Number of records of each table
+	Counts map[string]int `json:"counts"`
+}
+
+type articleRecord struct {
+	ID         string          `json:"id"`
+	Title      string          `json:"title"`
+	Format     int32           `json:"format"`
+	Content    json.RawMessage `json:"content"`
+	TokenIndex []byte          `json:"token_index,omitempty"`
+	HTML       []byte          `json:"html,omitempty"`
+}
+
+type queueRecord struct {
+	Title  string `json:"title"`
+	OnDate string `json:"on_date,omitempty"`
+}
+
+type gameRecord struct {
+	PlayerID string          `json:"player_id"`
+	GameID   string          `json:"game_id"`
+	Data     json.RawMessage `json:"data"`
+}
+
+type mediaRecord struct {
+	GameID      string `json:"game_id"`
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+// Export writes the articles, their media, the queue and the games to a zip
+// archive with a JSONL file per table and a manifest. The tables are read in a
+// single read-only transaction, streaming their rows into the archive.
+func Export(ctx context.Context, db *store.DB, w io.Writer) (Manifest, error) {
+	manifest := Manifest{
+		Version:   Version,
+		CreatedAt: time.Now().UTC(),
+		Counts:    map[string]int{},
+	}
+
+	zw := zip.NewWriter(w)
+
+	err := db.InSnapshot(ctx, func(s *store.Snapshot) error {
+		err := writeRecords(zw, manifest.CreatedAt, articleTable, func(write func(any) error) error {
+			err := s.EachArticle(ctx, func(article store.Article) error {
+				manifest.Counts[articleTable]++
+				return write(articleRecord{
+					ID:         article.ID,
+					Title:      article.Title,
+					Format:     article.Format,
+					Content:    article.Content,
+					TokenIndex: article.TokenIndex,
+					HTML:       article.Html,
+				})
+			})
+			if err != nil {
+				return fmt.Errorf("failed to list articles: %w", err)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = writeRecords(zw, manifest.CreatedAt, mediaTable, func(write func(any) error) error {
+			err := s.EachArticleMedium(ctx, func(medium store.Medium) error {
+				manifest.Counts[mediaTable]++
+				return write(mediaRecord{
+					GameID:      medium.GameID,
+					Name:        medium.Name,
+					ContentType: medium.ContentType,
+					Data:        medium.Data,
+				})
+			})
+			if err != nil {
+				return fmt.Errorf("failed to list media: %w", err)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = writeRecords(zw, manifest.CreatedAt, queueTable, func(write func(any) error) error {
+			err := s.EachQueueArticle(ctx, func(article store.ListQueueArticlesRow) error {
+				manifest.Counts[queueTable]++
+				return write(queueRecord{Title: article.Title, OnDate: article.Ondate.String})
+			})
+			if err != nil {
+				return fmt.Errorf("failed to list queued articles: %w", err)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return writeRecords(zw, manifest.CreatedAt, gameTable, func(write func(any) error) error {
+			err := s.EachGame(ctx, func(game store.Game) error {
+				manifest.Counts[gameTable]++
+				return write(gameRecord{PlayerID: game.PlayerID, GameID: game.GameID, Data: game.GameData})
+			})
+			if err != nil {
+				return fmt.Errorf("failed to list games: %w", err)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	// The manifest goes last so it only lists what was actually written
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: manifestFile, Method: zip.Deflate, Modified: manifest.CreatedAt})
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to create manifest: %w", err)
+	}
+
+	encoder := json.NewEncoder(mw)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(manifest)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	err = zw.Close()
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to close archive: %w", err)
+	}
+
+	return manifest, nil
+}
+
+func writeRecords(zw *zip.Writer, modified time.Time, table string, fn func(write func(any) error) error) error {
+	name := table + ".jsonl"
+
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+
+	buf := bufio.NewWriter(fw)
+	encoder := json.NewEncoder(buf)
+
+	err = fn(func(record any) error {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return buf.Flush()
+}
+
+// Import loads an archive in a single transaction. Articles and games replace
+// the stored ones with the same keys, and the media of each imported article
+// replaces all of its stored media. Queued articles are skipped if they are
+// already queued for the same date.
+func Import(ctx context.Context, db *store.DB, r io.ReaderAt, size int64) (Manifest, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	manifest, err := readManifest(zr)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	err = db.InTx(ctx, func(q *store.Queries) error {
+		err := readRecords(zr, manifest, articleTable, func(record articleRecord) error {
+			// The archive holds all the media of the article, media it no
+			// longer has must not outlive the import
+			err := q.DeleteMediaByGameID(ctx, record.ID)
+			if err != nil {
+				return fmt.Errorf("failed to delete media of %s: %w", record.ID, err)
+			}
+
+			return q.SaveArticle(ctx, store.SaveArticleParams{
+				ID:         record.ID,
+				Content:    record.Content,
+				Title:      record.Title,
+				Format:     record.Format,
+				TokenIndex: record.TokenIndex,
+				Html:       record.HTML,
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		err = readRecords(zr, manifest, mediaTable, func(record mediaRecord) error {
+			return q.SaveMedia(ctx, store.SaveMediaParams{
+				GameID:      record.GameID,
+				Name:        record.Name,
+				ContentType: record.ContentType,
+				Data:        record.Data,
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		queued, err := q.ListQueueArticles(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list queued articles: %w", err)
+		}
+
+		known := make(map[queueRecord]bool, len(queued))
+		for _, article := range queued {
+			known[queueRecord{Title: article.Title, OnDate: article.Ondate.String}] = true
+		}
+
+		err = readRecords(zr, manifest, queueTable, func(record queueRecord) error {
+			if known[record] {
+				return nil
+			}
+			known[record] = true
+
+			return q.AddArticleToQueue(ctx, store.AddArticleToQueueParams{
+				Title:  record.Title,
+				Ondate: sql.NullString{String: record.OnDate, Valid: record.OnDate != ""},
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		return readRecords(zr, manifest, gameTable, func(record gameRecord) error {
+			return q.SaveGame(ctx, store.SaveGameParams{
+				PlayerID: record.PlayerID,
+				GameID:   record.GameID,
+				GameData: record.Data,
+			})
+		})
+	})
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	return manifest, nil
+}
+
+func readManifest(zr *zip.Reader) (Manifest, error) {
+	file, err := zr.Open(manifestFile)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to open manifest: %w", err)
+	}
+	defer file.Close()
+
+	var manifest Manifest
+	err = json.NewDecoder(file).Decode(&manifest)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	if manifest.Version < 1 || manifest.Version > Version {
+		return Manifest{}, fmt.Errorf("unsupported archive version %d, expected at most %d", manifest.Version, Version)
+	}
+
+	return manifest, nil
+}
+
+// readRecords calls fn for every record of a file and checks that their number
+// matches the manifest
+func readRecords[T any](zr *zip.Reader, manifest Manifest, table string, fn func(record T) error) error {
+	name := table + ".jsonl"
+
+	file, err := zr.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	count := 0
+
+	for {
+		var record T
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read record %d of %s: %w", count+1, name, err)
+		}
+
+		err = fn(record)
+		if err != nil {
+			return fmt.Errorf("failed to import record %d of %s: %w", count+1, name, err)
+		}
+
+		count++
+	}
+
+	if count != manifest.Counts[table] {
+		return fmt.Errorf("%s has %d records, the manifest lists %d", name, count, manifest.Counts[table])
+	}
+
+	return nil
+}
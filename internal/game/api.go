@@ -1,11 +1,13 @@
 package game
 
 import (
+	"context"
 	"fmt"
 	"html/template"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gbandres98/wikidle2/internal/parser"
@@ -17,14 +19,38 @@ type Api struct {
 	db            *store.Queries
 	baseAddress   string
 	articleCache  bool
+	cacheLock     sync.Mutex
 	cachedArticle parser.Article
+	cachedIndex   indexPage
+	// Attempts added for each wrong full title guess
+	titlePenalty int
+	// Player data writes that haven't finished yet
+	pendingWrites sync.WaitGroup
 }
 
-func New(db *store.Queries, baseAddress string, articleCache bool) *Api {
+func New(db *store.Queries, baseAddress string, articleCache bool, titlePenalty int) *Api {
 	return &Api{
 		db:           db,
 		baseAddress:  baseAddress,
 		articleCache: articleCache,
+		titlePenalty: titlePenalty,
+	}
+}
+
+// Wait blocks until the pending player data writes finish or the context is
+// done
+func (a *Api) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		a.pendingWrites.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to wait for player data writes: %w", ctx.Err())
 	}
 }
 
@@ -33,6 +59,10 @@ func (a *Api) RegisterHandlers(mux *http.ServeMux) {
 
 	mux.HandleFunc("POST /init", a.playerDataMiddleware(a.handleInit))
 
+	mux.HandleFunc("POST /title", a.playerDataMiddleware(a.handleTitleGuess))
+
+	mux.HandleFunc("GET /media/{gameID}/{name}", a.handleMedia)
+
 	mux.HandleFunc("GET /{$}", a.handleGet)
 
 }
@@ -40,16 +70,16 @@ func (a *Api) RegisterHandlers(mux *http.ServeMux) {
 func (a *Api) handleWordSearch(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	article, err := a.getArticleOfTheDay(ctx, articleID(ctx))
+	article, err := a.getArticleIndex(ctx, articleID(ctx))
 	if err != nil {
-		Error(w, err, http.StatusInternalServerError, "", "failed to get article of the day")
+		Error(w, r, err, http.StatusInternalServerError, "", "failed to get article of the day")
 		return
 	}
 
 	playerData := playerData(ctx)
 
 	newWord := r.FormValue("q")
-	if len(strings.TrimSpace(newWord)) == 0 {
+	if len(strings.TrimSpace(newWord)) == 0 || playerData.Game.finished() {
 		w.WriteHeader(http.StatusAccepted)
 		return
 	}
@@ -61,101 +91,129 @@ func (a *Api) handleWordSearch(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	for _, word := range parser.ExcludedWords {
-		if word == parser.Normalize(newWord) {
-			w.WriteHeader(http.StatusAccepted)
-			return
-		}
+	if article.RevealPolicy().IsRevealed(newWord) {
+		w.WriteHeader(http.StatusAccepted)
+		return
 	}
 
+	playerData.startGame()
 	playerData.Game.Words = append(playerData.Game.Words, newWord)
+	guesses.WithLabelValues("word").Inc()
 
-	won := checkGameWin(playerData.Game, article)
-
-	playerData.Game.Won = won
-
-	if won {
-		playerData.LastStreak = time.Now()
-		playerData.Streak++
+	if checkGameWin(playerData.Game, article) {
+		playerData.winGame()
 
-		_, err := w.Write([]byte(fmt.Sprintf(`<div id="article" hx-swap-oob="true"><base href="//es.wikipedia.org/wiki/">%s</div>`, string(article.UnobscuredHTML))))
+		err = a.writeGameOver(ctx, w, article, playerData)
 		if err != nil {
-			Error(w, err, http.StatusInternalServerError, "", "failed to write unobscured article")
+			Error(w, r, err, http.StatusInternalServerError, "", "failed to write game over")
 			return
 		}
 
-		_, err = w.Write([]byte(fmt.Sprintf(`<p id="motd" hx-swap-oob="true">Adivinaste el artículo de hoy en %d intentos!</p>`, len(playerData.Game.Words))))
-		if err != nil {
-			Error(w, err, http.StatusInternalServerError, "", "failed to write MOTD")
-			return
-		}
+		return
+	}
 
-		err = a.writeGameWinModal(r.Context(), w, article, playerData)
-		if err != nil {
-			Error(w, err, http.StatusInternalServerError, "", "failed to write game win modal")
-			return
-		}
+	attIndex := len(playerData.Game.Words)
 
-		_, err = w.Write([]byte(`<script>onGameWin();</script>`))
-		if err != nil {
-			Error(w, err, http.StatusInternalServerError, "", "failed to write onGameWin script")
-			return
-		}
+	hits, err := writeHits(w, newWord, attIndex, article, hiddenWords(playerData.Game, article))
+	if err != nil {
+		Error(w, r, err, http.StatusInternalServerError, "", "failed to write hits")
+		return
+	}
 
+	_, err = w.Write([]byte(fmt.Sprintf(`<small onclick="scrollToNextWord(%d)">%d. %s - %d aciertos</small>`, attIndex, attIndex, r.FormValue("q"), hits)))
+	if err != nil {
+		Error(w, r, err, http.StatusInternalServerError, "", "failed to write hit word")
 		return
 	}
 
-	attIndex := len(playerData.Game.Words)
+	err = a.writeImages(w, article, playerData.Game, playerData.Game.Words[:attIndex-1], playerData.Game.attempts()-1)
+	if err != nil {
+		Error(w, r, err, http.StatusInternalServerError, "", "failed to write images")
+		return
+	}
 
-	hits, err := writeHits(w, newWord, attIndex, article)
+	err = a.writeClue(w, article, playerData.Game, attIndex)
 	if err != nil {
-		Error(w, err, http.StatusInternalServerError, "", "failed to write hits")
+		Error(w, r, err, http.StatusInternalServerError, "", "failed to write clue")
 		return
 	}
+}
 
-	_, err = w.Write([]byte(fmt.Sprintf(`<small onclick="scrollToNextWord(%d)">%d. %s - %d aciertos</small>`, attIndex, attIndex, r.FormValue("q"), hits)))
+func (a *Api) handleTitleGuess(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	article, err := a.getArticleIndex(ctx, articleID(ctx))
 	if err != nil {
-		Error(w, err, http.StatusInternalServerError, "", "failed to write hit word")
+		Error(w, r, err, http.StatusInternalServerError, "", "failed to get article of the day")
+		return
+	}
+
+	playerData := playerData(ctx)
+
+	guess := strings.TrimSpace(r.FormValue("title"))
+	if len(guess) == 0 || playerData.Game.finished() {
+		w.WriteHeader(http.StatusAccepted)
 		return
 	}
 
-	err = a.writeClue(w, article, attIndex)
+	for _, previous := range playerData.Game.TitleGuesses {
+		if parser.Normalize(previous) == parser.Normalize(guess) {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+	}
+
+	playerData.startGame()
+	playerData.Game.TitleGuesses = append(playerData.Game.TitleGuesses, guess)
+	guesses.WithLabelValues("title").Inc()
+
+	if parser.MatchesTitle(guess, article) {
+		playerData.winGame()
+	} else if playerData.Game.mode() == ModeTitle {
+		playerData.loseGame()
+	} else {
+		playerData.Game.Penalty += a.titlePenalty
+
+		_, err = w.Write([]byte(fmt.Sprintf(`<small>%s - no es el título, +%d intentos</small>`, template.HTMLEscapeString(guess), a.titlePenalty)))
+		if err != nil {
+			Error(w, r, err, http.StatusInternalServerError, "", "failed to write wrong title guess")
+			return
+		}
+
+		err = a.writeImages(w, article, playerData.Game, playerData.Game.Words, playerData.Game.attempts()-a.titlePenalty)
+		if err != nil {
+			Error(w, r, err, http.StatusInternalServerError, "", "failed to write images")
+		}
+
+		return
+	}
+
+	err = a.writeGameOver(ctx, w, article, playerData)
 	if err != nil {
-		Error(w, err, http.StatusInternalServerError, "", "failed to write clue")
+		Error(w, r, err, http.StatusInternalServerError, "", "failed to write game over")
 		return
 	}
 }
 
 func (a *Api) handleGet(w http.ResponseWriter, r *http.Request) {
-	motd := "Adivina el artículo de hoy"
+	page, err := a.getIndexPage(r.Context(), parser.GetGameID(time.Now()))
+	if err != nil {
+		Error(w, r, err, http.StatusInternalServerError, "", "failed to render index")
+		return
+	}
 
-	modal := template.HTML("")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", page.etag)
 
-	article, err := a.getArticleOfTheDay(r.Context(), parser.GetGameID(time.Now()))
-	if err != nil {
-		Error(w, err, http.StatusInternalServerError, "", "failed to get article of the day")
+	if etagMatches(r.Header.Get("If-None-Match"), page.etag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
-	err = templates.Execute(w, "index.html", struct {
-		BaseUrl           string
-		Article           template.HTML
-		Attempts          template.HTML
-		MOTD              string
-		Won               bool
-		Modal             template.HTML
-		SearchPlaceholder string
-	}{
-		BaseUrl:           a.baseAddress,
-		Article:           article.HTML,
-		Attempts:          template.HTML(""),
-		MOTD:              motd,
-		Won:               false,
-		Modal:             modal,
-		SearchPlaceholder: "Cargando el artículo de hoy...",
-	})
+	_, err = w.Write(page.body)
 	if err != nil {
-		Error(w, err, http.StatusInternalServerError, "", "failed to execute template")
+		Error(w, r, err, http.StatusInternalServerError, "", "failed to write index")
 		return
 	}
 }
@@ -165,68 +223,116 @@ func (a *Api) handleInit(w http.ResponseWriter, r *http.Request) {
 
 	article, err := a.getArticleOfTheDay(ctx, articleID(ctx))
 	if err != nil {
-		Error(w, err, http.StatusInternalServerError, "", "failed to get article of the day")
+		Error(w, r, err, http.StatusInternalServerError, "", "failed to get article of the day")
 		return
 	}
 
 	playerData := playerData(ctx)
 
-	if playerData.Game.Won {
-		_, err := w.Write([]byte(fmt.Sprintf(`<div id="article" hx-swap-oob="true"><base href="//es.wikipedia.org/wiki/">%s</div>`, string(article.UnobscuredHTML))))
+	if playerData.Game.finished() {
+		err = a.writeGameOver(ctx, w, article, playerData)
 		if err != nil {
-			Error(w, err, http.StatusInternalServerError, "", "failed to write unobscured article")
+			Error(w, r, err, http.StatusInternalServerError, "", "failed to write game over")
 			return
 		}
 
-		_, err = w.Write([]byte(fmt.Sprintf(`<p id="motd" hx-swap-oob="true">Adivinaste el artículo de hoy en %d intentos!</p>`, len(playerData.Game.Words))))
+		return
+	}
+
+	if playerData.Game.mode() == ModeEasy {
+		err = writeRevealed(w, article.LeadWords, article)
 		if err != nil {
-			Error(w, err, http.StatusInternalServerError, "", "failed to write MOTD")
+			Error(w, r, err, http.StatusInternalServerError, "", "failed to write lead words")
 			return
 		}
+	}
 
-		err = a.writeGameWinModal(r.Context(), w, article, playerData)
+	hidden := hiddenWords(playerData.Game, article)
+
+	for attIndex, word := range playerData.Game.Words {
+		hits, err := writeHits(w, word, attIndex, article, hidden)
 		if err != nil {
-			Error(w, err, http.StatusInternalServerError, "", "failed to write game win modal")
+			Error(w, r, err, http.StatusInternalServerError, "", "failed to write hits")
 			return
 		}
 
-		_, err = w.Write([]byte(`<script>onGameWin();</script>`))
+		_, err = w.Write([]byte(fmt.Sprintf(`<small onclick="scrollToNextWord(%d)">%d. %s - %d aciertos</small>`, attIndex, attIndex, word, hits)))
 		if err != nil {
-			Error(w, err, http.StatusInternalServerError, "", "failed to write onGameWin script")
+			Error(w, r, err, http.StatusInternalServerError, "", "failed to write hit word")
 			return
 		}
-
-		return
 	}
 
-	for attIndex, word := range playerData.Game.Words {
-		hits, err := writeHits(w, word, attIndex, article)
+	for _, guess := range playerData.Game.TitleGuesses {
+		_, err = w.Write([]byte(fmt.Sprintf(`<small>%s - no es el título, +%d intentos</small>`, template.HTMLEscapeString(guess), a.titlePenalty)))
 		if err != nil {
-			Error(w, err, http.StatusInternalServerError, "", "failed to write hits")
+			Error(w, r, err, http.StatusInternalServerError, "", "failed to write wrong title guess")
 			return
 		}
+	}
 
-		_, err = w.Write([]byte(fmt.Sprintf(`<small onclick="scrollToNextWord(%d)">%d. %s - %d aciertos</small>`, attIndex, attIndex, word, hits)))
+	err = a.writeImages(w, article, playerData.Game, nil, 0)
+	if err != nil {
+		Error(w, r, err, http.StatusInternalServerError, "", "failed to write images")
+		return
+	}
+
+	err = a.writeClue(w, article, playerData.Game, len(playerData.Game.Words))
+	if err != nil {
+		Error(w, r, err, http.StatusInternalServerError, "", "failed to write clue")
+		return
+	}
+}
+
+// writeGameOver reveals the article and shows the game over modal
+func (a *Api) writeGameOver(ctx context.Context, w http.ResponseWriter, article parser.Article, playerData *PlayerData) error {
+	if article.UnobscuredHTML == "" {
+		var err error
+		article, err = a.getArticleOfTheDay(ctx, article.ID)
 		if err != nil {
-			Error(w, err, http.StatusInternalServerError, "", "failed to write hit word")
-			return
+			return err
 		}
 	}
 
-	err = a.writeClue(w, article, len(playerData.Game.Words))
+	attribution, err := templates.Render("attribution.html", article)
 	if err != nil {
-		Error(w, err, http.StatusInternalServerError, "", "failed to write clue")
-		return
+		return fmt.Errorf("failed to render attribution: %w", err)
+	}
+
+	_, err = w.Write([]byte(fmt.Sprintf(`<div id="article" hx-swap-oob="true"><base href="//es.wikipedia.org/wiki/">%s%s</div>`, string(article.UnobscuredHTML), string(attribution))))
+	if err != nil {
+		return fmt.Errorf("failed to write unobscured article: %w", err)
 	}
+
+	motd := fmt.Sprintf("Adivinaste el artículo de hoy en %d intentos!", playerData.Game.attempts())
+	if playerData.Game.Lost {
+		motd = "No adivinaste el artículo de hoy"
+	}
+
+	_, err = w.Write([]byte(fmt.Sprintf(`<p id="motd" hx-swap-oob="true">%s</p>`, motd)))
+	if err != nil {
+		return fmt.Errorf("failed to write MOTD: %w", err)
+	}
+
+	err = a.writeGameWinModal(ctx, w, article, playerData)
+	if err != nil {
+		return fmt.Errorf("failed to write game win modal: %w", err)
+	}
+
+	_, err = w.Write([]byte(`<script>onGameWin();</script>`))
+	if err != nil {
+		return fmt.Errorf("failed to write onGameWin script: %w", err)
+	}
+
+	return nil
 }
 
-func Error(w http.ResponseWriter, err error, code int, message string, logMessage string, logArgs ...interface{}) {
+func Error(w http.ResponseWriter, r *http.Request, err error, code int, message string, logMessage string, logArgs ...interface{}) {
 	if message == "" {
 		message = "Lo siento, ha ocurrido un error."
 	}
 
-	log.Printf(logMessage+"\n", logArgs...)
-	log.Println(err)
+	slog.ErrorContext(r.Context(), fmt.Sprintf(logMessage, logArgs...), "error", err, "status", code)
 
 	http.Error(w, message, code)
 }
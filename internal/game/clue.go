@@ -8,8 +8,8 @@ import (
 	"github.com/gbandres98/wikidle2/internal/templates"
 )
 
-func (a *Api) writeClue(w http.ResponseWriter, article parser.Article, attemptNumber int) error {
-	if article.Clues == nil {
+func (a *Api) writeClue(w http.ResponseWriter, article parser.Article, gameData *GameData, attemptNumber int) error {
+	if article.Clues == nil || gameData.mode() == ModeHard {
 		return nil
 	}
 
@@ -2,7 +2,6 @@ package game
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/http"
@@ -48,10 +47,16 @@ func (a *Api) init(gameData *GameData, article parser.Article, w http.ResponseWr
 
 func checkGameWin(gameData *GameData, article parser.Article) bool {
 	remaining := len(article.TitleTokens)
+	policy := article.RevealPolicy()
 
 	for _, titleToken := range article.TitleTokens {
+		if policy.IsRevealed(titleToken) {
+			remaining--
+			continue
+		}
+
 		for _, word := range gameData.Words {
-			if parser.Normalize(word) == titleToken || parser.IsExcludedWord(titleToken) {
+			if parser.Normalize(word) == titleToken {
 				remaining--
 				break
 			}
@@ -62,33 +67,55 @@ func checkGameWin(gameData *GameData, article parser.Article) bool {
 }
 
 func (a *Api) getArticleOfTheDay(ctx context.Context, articleID string) (parser.Article, error) {
-	if a.articleCache && a.cachedArticle.ID == articleID {
-		return a.cachedArticle, nil
+	if article, ok := a.getCachedArticle(articleID); ok {
+		return article, nil
 	}
 
-	storeArticle, err := a.db.GetArticleByID(ctx, articleID)
+	article, err := parser.LoadArticle(ctx, a.db, articleID)
 	if err != nil {
 		return parser.Article{}, err
 	}
 
-	var article parser.Article
-	err = json.Unmarshal(storeArticle.Content, &article)
-	if err != nil {
-		return parser.Article{}, err
-	}
+	article.HTML = a.withMediaAddress(article.HTML)
+	article.UnobscuredHTML = a.withMediaAddress(article.UnobscuredHTML)
 
+	a.cacheLock.Lock()
 	a.cachedArticle = article
+	a.cacheLock.Unlock()
 
 	return article, nil
 }
 
-func writeHits(w http.ResponseWriter, word string, attIndex int, article parser.Article) (int, error) {
+// getArticleIndex returns the article of the day without its HTML, unless it
+// is already cached
+func (a *Api) getArticleIndex(ctx context.Context, articleID string) (parser.Article, error) {
+	if article, ok := a.getCachedArticle(articleID); ok {
+		return article, nil
+	}
+
+	return parser.LoadArticleIndex(ctx, a.db, articleID)
+}
+
+func (a *Api) getCachedArticle(articleID string) (parser.Article, bool) {
+	a.cacheLock.Lock()
+	defer a.cacheLock.Unlock()
+
+	if a.articleCache && a.cachedArticle.ID == articleID {
+		articleCacheRequests.WithLabelValues("hit").Inc()
+		return a.cachedArticle, true
+	}
+
+	articleCacheRequests.WithLabelValues("miss").Inc()
+	return parser.Article{}, false
+}
+
+func writeHits(w http.ResponseWriter, word string, attIndex int, article parser.Article, hidden map[int]bool) (int, error) {
 	hits := 0
 
 	if indexes, ok := article.Tokens[parser.Normalize(word)]; ok {
 		for n, i := range indexes {
 			word, ok := article.Words[i]
-			if !ok {
+			if !ok || hidden[i] {
 				continue
 			}
 
@@ -103,3 +130,20 @@ func writeHits(w http.ResponseWriter, word string, attIndex int, article parser.
 
 	return hits, nil
 }
+
+// writeRevealed reveals obscured spans without counting them as hits
+func writeRevealed(w http.ResponseWriter, indexes []int, article parser.Article) error {
+	for _, i := range indexes {
+		word, ok := article.Words[i]
+		if !ok {
+			continue
+		}
+
+		_, err := w.Write([]byte(fmt.Sprintf(`<span id="obscured-%d" hx-swap-oob="true">%s</span>`, i, word)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package game
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/wikidle2/internal/parser"
+	"github.com/gbandres98/wikidle2/internal/store"
+)
+
+func (a *Api) handleMedia(w http.ResponseWriter, r *http.Request) {
+	media, err := a.db.GetMedia(r.Context(), store.GetMediaParams{
+		GameID: r.PathValue("gameID"),
+		Name:   r.PathValue("name"),
+	})
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		Error(w, r, err, http.StatusInternalServerError, "", "failed to get media %s", r.URL.Path)
+		return
+	}
+
+	sum := sha256.Sum256(media.Data)
+
+	w.Header().Set("Content-Type", media.ContentType)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])[:16]+`"`)
+
+	http.ServeContent(w, r, media.Name, time.Time{}, bytes.NewReader(media.Data))
+}
+
+// withMediaAddress makes the media URLs of an article absolute, since the
+// unobscured article is rendered with a <base> pointing to Wikipedia
+func (a *Api) withMediaAddress(html template.HTML) template.HTML {
+	return template.HTML(strings.ReplaceAll(string(html), `src="`+parser.MediaPath, `src="`+a.baseAddress+parser.MediaPath))
+}
+
+// Attempts needed to lower the obscurity of every image by one level
+const attemptsPerImageLevel = 15
+
+// revealedSpans returns the obscured spans revealed by the guessed words
+func revealedSpans(words []string, article parser.Article, hidden map[int]bool) map[int]bool {
+	revealed := make(map[int]bool)
+
+	for _, word := range words {
+		for _, i := range article.Tokens[parser.Normalize(word)] {
+			if !hidden[i] {
+				revealed[i] = true
+			}
+		}
+	}
+
+	return revealed
+}
+
+// imageLevel returns how revealed an image is, from the number of attempts or
+// the guessed words of its caption
+func imageLevel(image parser.ArticleImage, attempts int, revealed map[int]bool) int {
+	top := len(image.Levels) - 1
+	if top <= 0 {
+		return 0
+	}
+
+	level := attempts / attemptsPerImageLevel
+
+	if len(image.Caption) > 0 {
+		hits := 0
+		for _, i := range image.Caption {
+			if revealed[i] {
+				hits++
+			}
+		}
+
+		level = max(level, hits*top/len(image.Caption))
+	}
+
+	return min(level, top)
+}
+
+// writeImages swaps the images that are less obscured than they were with the
+// previous words and attempts
+func (a *Api) writeImages(w http.ResponseWriter, article parser.Article, gameData *GameData, previousWords []string, previousAttempts int) error {
+	hidden := hiddenWords(gameData, article)
+	revealed := revealedSpans(gameData.Words, article, hidden)
+	previouslyRevealed := revealedSpans(previousWords, article, hidden)
+
+	for i, image := range article.Images {
+		level := imageLevel(image, gameData.attempts(), revealed)
+		if level == imageLevel(image, previousAttempts, previouslyRevealed) {
+			continue
+		}
+
+		_, err := w.Write([]byte(fmt.Sprintf(`<img id="%s" hx-swap-oob="true" src="%s%s" width="%s" height="%s"/>`,
+			parser.ImageID(i), a.baseAddress, image.Levels[level], image.Width, image.Height)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package game
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	guesses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wikidle_guesses_total",
+		Help: "Guesses made by players, by kind (word, title).",
+	}, []string{"kind"})
+	gamesFinished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wikidle_games_finished_total",
+		Help: "Games finished, by mode and result (won, lost).",
+	}, []string{"mode", "result"})
+	gameAttempts = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "wikidle_game_attempts",
+		Help:    "Attempts, including penalties, needed to finish a game, by mode.",
+		Buckets: []float64{5, 10, 20, 30, 50, 75, 100, 150, 200, 300},
+	}, []string{"mode"})
+	articleCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wikidle_article_cache_requests_total",
+		Help: "Article of the day lookups, by result (hit, miss).",
+	}, []string{"result"})
+)
+
+func RegisterMetrics(r prometheus.Registerer) {
+	r.MustRegister(guesses, gamesFinished, gameAttempts, articleCacheRequests)
+}
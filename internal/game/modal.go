@@ -4,33 +4,46 @@ import (
 	"context"
 	"fmt"
 	"html/template"
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/gbandres98/wikidle2/internal/parser"
+	"github.com/gbandres98/wikidle2/internal/store"
 	"github.com/gbandres98/wikidle2/internal/templates"
 )
 
 type modalTemplateData struct {
 	ArticleTitle string
+	Attribution  template.HTML
+	Won          bool
+	Mode         string
 	Attempts     int
+	TitleGuesses []string
 	TotalPlayers int64
 	TotalWins    int64
 	Streak       int
+	Played       int
+	WonGames     int
 	Words        []template.HTML
 }
 
 func (a *Api) createGameWinModalData(ctx context.Context, article parser.Article, playerData *PlayerData) (modalTemplateData, error) {
 	id := article.ID
 
-	totalPlayers, err := a.db.GetGameCountByGameID(ctx, id)
+	totalPlayers, err := a.db.GetGameCountByGameIDAndMode(ctx, store.GetGameCountByGameIDAndModeParams{
+		GameID: id,
+		Mode:   playerData.Game.Mode,
+	})
 	if err != nil {
-		log.Printf("failed to get games by game id: %v", err)
+		slog.ErrorContext(ctx, "failed to get games by game id", "error", err)
 	}
 
-	totalWins, err := a.db.GetWinCountByGameID(ctx, id)
+	totalWins, err := a.db.GetWinCountByGameIDAndMode(ctx, store.GetWinCountByGameIDAndModeParams{
+		GameID: id,
+		Mode:   playerData.Game.Mode,
+	})
 	if err != nil {
-		log.Printf("failed to get won games by game id: %v", err)
+		slog.ErrorContext(ctx, "failed to get won games by game id", "error", err)
 	}
 
 	words := []template.HTML{}
@@ -39,12 +52,25 @@ func (a *Api) createGameWinModalData(ctx context.Context, article parser.Article
 		words = append(words, template.HTML(fmt.Sprintf("<small style='display:block'>%d. %s</small>", i+1, word)))
 	}
 
+	stats := playerData.stats()
+
+	attribution, err := templates.Render("attribution.html", article)
+	if err != nil {
+		return modalTemplateData{}, fmt.Errorf("failed to render attribution: %w", err)
+	}
+
 	return modalTemplateData{
 		ArticleTitle: article.Title,
-		Attempts:     len(playerData.Game.Words),
+		Attribution:  attribution,
+		Won:          playerData.Game.Won,
+		Mode:         modeNames[playerData.Game.mode()],
+		Attempts:     playerData.Game.attempts(),
+		TitleGuesses: playerData.Game.TitleGuesses,
 		TotalPlayers: totalPlayers,
 		TotalWins:    totalWins,
-		Streak:       playerData.Streak,
+		Streak:       stats.Streak,
+		Played:       stats.Played,
+		WonGames:     stats.Won,
 		Words:        words,
 	}, nil
 }
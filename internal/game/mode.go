@@ -0,0 +1,112 @@
+package game
+
+import (
+	"time"
+
+	"github.com/gbandres98/wikidle2/internal/parser"
+)
+
+const (
+	ModeNormal = "normal"
+	// Common words of the lead paragraph are revealed from the start
+	ModeEasy = "easy"
+	// Headings and image captions stay hidden and there are no clues
+	ModeHard = "hard"
+	// Words can be guessed as usual, but a wrong full title guess loses the
+	// game instead of adding a penalty
+	ModeTitle = "title"
+)
+
+var modeNames = map[string]string{
+	ModeNormal: "Normal",
+	ModeEasy:   "Fácil",
+	ModeHard:   "Difícil",
+	ModeTitle:  "A la primera",
+}
+
+func isMode(mode string) bool {
+	_, ok := modeNames[mode]
+	return ok
+}
+
+type ModeStats struct {
+	Played     int       `json:"p"`
+	Won        int       `json:"w"`
+	Streak     int       `json:"s"`
+	LastStreak time.Time `json:"t"`
+}
+
+// mode returns the game mode, games stored before modes existed are normal
+func (g *GameData) mode() string {
+	if g.Mode == "" {
+		return ModeNormal
+	}
+
+	return g.Mode
+}
+
+func (g *GameData) started() bool {
+	return len(g.Words) > 0 || len(g.TitleGuesses) > 0
+}
+
+// attempts returns the number of attempts including penalties
+func (g *GameData) attempts() int {
+	return len(g.Words) + g.Penalty
+}
+
+func (g *GameData) finished() bool {
+	return g.Won || g.Lost
+}
+
+// stats returns the stats of the current game mode
+func (p *PlayerData) stats() *ModeStats {
+	if p.Stats == nil {
+		p.Stats = make(map[string]*ModeStats)
+	}
+
+	mode := p.Game.mode()
+	if _, ok := p.Stats[mode]; !ok {
+		p.Stats[mode] = &ModeStats{}
+	}
+
+	return p.Stats[mode]
+}
+
+// startGame must be called before the first attempt of a game is stored
+func (p *PlayerData) startGame() {
+	if !p.Game.started() {
+		p.stats().Played++
+	}
+}
+
+func (p *PlayerData) winGame() {
+	p.Game.Won = true
+	gamesFinished.WithLabelValues(p.Game.mode(), "won").Inc()
+	gameAttempts.WithLabelValues(p.Game.mode()).Observe(float64(p.Game.attempts()))
+
+	stats := p.stats()
+	stats.Won++
+	stats.Streak++
+	stats.LastStreak = time.Now()
+}
+
+func (p *PlayerData) loseGame() {
+	p.Game.Lost = true
+	gamesFinished.WithLabelValues(p.Game.mode(), "lost").Inc()
+	gameAttempts.WithLabelValues(p.Game.mode()).Observe(float64(p.Game.attempts()))
+	p.stats().Streak = 0
+}
+
+// hiddenWords returns the obscured spans that can't be revealed in the game
+// mode
+func hiddenWords(gameData *GameData, article parser.Article) map[int]bool {
+	hidden := make(map[int]bool)
+
+	if gameData.mode() == ModeHard {
+		for _, i := range article.HeadingWords {
+			hidden[i] = true
+		}
+	}
+
+	return hidden
+}
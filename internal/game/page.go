@@ -0,0 +1,85 @@
+package game
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"strings"
+
+	"github.com/gbandres98/wikidle2/internal/templates"
+)
+
+// indexPage is the rendered index of a day, which is the same for every player
+// since their game is loaded afterwards
+type indexPage struct {
+	articleID string
+	body      []byte
+	etag      string
+}
+
+func (a *Api) getIndexPage(ctx context.Context, articleID string) (indexPage, error) {
+	a.cacheLock.Lock()
+	cached := a.cachedIndex
+	a.cacheLock.Unlock()
+
+	if a.articleCache && cached.articleID == articleID {
+		return cached, nil
+	}
+
+	article, err := a.getArticleOfTheDay(ctx, articleID)
+	if err != nil {
+		return indexPage{}, err
+	}
+
+	body, err := templates.Render("index.html", struct {
+		BaseUrl           string
+		Article           template.HTML
+		Attempts          template.HTML
+		MOTD              string
+		Won               bool
+		Modal             template.HTML
+		SearchPlaceholder string
+	}{
+		BaseUrl:           a.baseAddress,
+		Article:           article.HTML,
+		Attempts:          template.HTML(""),
+		MOTD:              "Adivina el artículo de hoy",
+		Won:               false,
+		Modal:             template.HTML(""),
+		SearchPlaceholder: "Cargando el artículo de hoy...",
+	})
+	if err != nil {
+		return indexPage{}, err
+	}
+
+	sum := sha256.Sum256([]byte(body))
+	page := indexPage{
+		articleID: articleID,
+		body:      []byte(body),
+		etag:      `"` + hex.EncodeToString(sum[:])[:16] + `"`,
+	}
+
+	a.cacheLock.Lock()
+	a.cachedIndex = page
+	a.cacheLock.Unlock()
+
+	return page, nil
+}
+
+// etagMatches reports whether an If-None-Match header matches an ETag, using
+// the weak comparison since compressed responses carry weak ETags
+func etagMatches(header string, etag string) bool {
+	if header == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,13 +1,10 @@
 package game
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -17,16 +14,24 @@ import (
 )
 
 type GameData struct {
-	Words     []string `json:"s"`
-	Won       bool     `json:"w"`
-	ArticleID string   `json:"i"`
+	Words        []string `json:"s"`
+	Won          bool     `json:"w"`
+	ArticleID    string   `json:"i"`
+	Mode         string   `json:"m,omitempty"`
+	TitleGuesses []string `json:"g,omitempty"`
+	// Attempts added by wrong full title guesses
+	Penalty int  `json:"p,omitempty"`
+	Lost    bool `json:"l,omitempty"`
 }
 
 type PlayerData struct {
-	ID         string    `json:"i"`
-	Game       *GameData `json:"g"`
-	Streak     int       `json:"s"`
-	LastStreak time.Time `json:"t"`
+	ID   string    `json:"i"`
+	Game *GameData `json:"g"`
+	// Game mode -> stats
+	Stats map[string]*ModeStats `json:"m,omitempty"`
+	// Normal mode streak stored before modes existed, moved to Stats on read
+	LegacyStreak     int        `json:"s,omitempty"`
+	LegacyLastStreak *time.Time `json:"t,omitempty"`
 }
 
 func (a *Api) playerDataMiddleware(next http.HandlerFunc) http.HandlerFunc {
@@ -34,7 +39,17 @@ func (a *Api) playerDataMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		articleID := parser.GetGameID(time.Now())
 		playerData, err := readPlayerDataHeader(r, articleID)
 		if err != nil {
-			Error(w, err, 500, "", "error retrieving player data", articleID)
+			Error(w, r, err, http.StatusInternalServerError, "", "error retrieving player data for game %s", articleID)
+		}
+
+		mode := r.FormValue("mode")
+		if isMode(mode) && !playerData.Game.started() {
+			// Normal games are stored without a mode, like the ones played
+			// before modes existed
+			if mode == ModeNormal {
+				mode = ""
+			}
+			playerData.Game.Mode = mode
 		}
 
 		ctx := r.Context()
@@ -43,7 +58,7 @@ func (a *Api) playerDataMiddleware(next http.HandlerFunc) http.HandlerFunc {
 
 		next(w, r.WithContext(ctx))
 
-		a.writePlayerDataHeader(w, playerData)
+		a.writePlayerDataHeader(ctx, w, playerData)
 	}
 }
 
@@ -55,35 +70,22 @@ func articleID(ctx context.Context) string {
 	return ctx.Value("articleID").(string)
 }
 
-func (a *Api) writePlayerDataHeader(w http.ResponseWriter, playerData *PlayerData) {
+func (a *Api) writePlayerDataHeader(ctx context.Context, w http.ResponseWriter, playerData *PlayerData) {
+	a.pendingWrites.Add(1)
 	go func() {
-		err := a.storePlayerData(context.Background(), playerData)
+		defer a.pendingWrites.Done()
+
+		err := a.storePlayerData(context.WithoutCancel(ctx), playerData)
 		if err != nil {
-			log.Printf("failed to store player data: %v\n", err)
+			slog.ErrorContext(ctx, "failed to store player data", "player_id", playerData.ID, "error", err)
 		}
 	}()
 
-	var buf bytes.Buffer
-	gz := gzip.NewWriter(&buf)
-
-	err := json.NewEncoder(gz).Encode(playerData)
+	js, err := json.Marshal(playerData)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "failed to encode player data", "player_id", playerData.ID, "error", err)
 		return
 	}
-	gz.Close()
-
-	encodedString := base64.StdEncoding.EncodeToString(buf.Bytes())
-
-	log.Println("------")
-
-	log.Printf("gzip: %d\n", len(encodedString))
-
-	js, _ := json.Marshal(playerData)
-
-	log.Printf("json: %d\n", len(js))
-
-	log.Printf("base64: %d\n", len(base64.StdEncoding.EncodeToString(js)))
 
 	_, err = w.Write([]byte(fmt.Sprintf(`<span id="game-data" hx-swap-oob="true">%s</span>`, js)))
 }
@@ -104,7 +106,7 @@ func readPlayerDataHeader(r *http.Request, articleID string) (*PlayerData, error
 
 	err := json.Unmarshal([]byte(data), playerData)
 	if err != nil {
-		log.Printf("error unmarshalling player data: %s", err)
+		slog.WarnContext(r.Context(), "failed to unmarshal player data", "error", err)
 		return playerData, nil
 	}
 
@@ -115,8 +117,23 @@ func readPlayerDataHeader(r *http.Request, articleID string) (*PlayerData, error
 		}
 	}
 
-	if playerData.LastStreak.Before(time.Now().AddDate(0, 0, -3)) {
-		playerData.Streak = 0
+	if playerData.LegacyLastStreak != nil {
+		if playerData.Stats == nil {
+			playerData.Stats = make(map[string]*ModeStats)
+		}
+
+		playerData.Stats[ModeNormal] = &ModeStats{
+			Streak:     playerData.LegacyStreak,
+			LastStreak: *playerData.LegacyLastStreak,
+		}
+		playerData.LegacyStreak = 0
+		playerData.LegacyLastStreak = nil
+	}
+
+	for _, stats := range playerData.Stats {
+		if stats.LastStreak.Before(time.Now().AddDate(0, 0, -3)) {
+			stats.Streak = 0
+		}
 	}
 
 	return playerData, nil
@@ -0,0 +1,113 @@
+// Package health serves the liveness and readiness endpoints of both binaries.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Time each check has to complete
+const checkTimeout = 5 * time.Second
+
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Register adds GET /healthz, which only reports that the process is serving
+// requests, and GET /readyz, which runs the checks. GET /health is kept as an
+// alias of /healthz for existing probes.
+func Register(mux *http.ServeMux, checks ...Check) {
+	mux.HandleFunc("GET /health", handleLiveness)
+	mux.HandleFunc("GET /healthz", handleLiveness)
+	mux.HandleFunc("GET /readyz", readinessHandler(checks))
+}
+
+func handleLiveness(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, r, Report{Status: StatusOK})
+}
+
+func readinessHandler(checks []Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, r, Run(r.Context(), checks))
+	}
+}
+
+// Run runs every check concurrently and reports an error if any of them fails
+func Run(ctx context.Context, checks []Check) Report {
+	report := Report{
+		Status: StatusOK,
+		Checks: make(map[string]CheckResult, len(checks)),
+	}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.Run(ctx)
+
+			result := CheckResult{Status: StatusOK, Duration: time.Since(start).String()}
+			if err != nil {
+				result.Status = StatusError
+				result.Error = err.Error()
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			report.Checks[check.Name] = result
+			if err != nil {
+				report.Status = StatusError
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return report
+}
+
+func writeReport(w http.ResponseWriter, r *http.Request, report Report) {
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+		slog.WarnContext(r.Context(), "readiness check failed", "report", report.Checks)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(report)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to write health report", "error", err)
+	}
+}
@@ -0,0 +1,69 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Alerter notifies the maintainers when the article of the day can't be
+// parsed
+type Alerter interface {
+	Alert(ctx context.Context, message string) error
+}
+
+// NewAlerter returns an alerter that posts to the webhook, or one that only
+// logs the alerts when there is no webhook
+func NewAlerter(webhookURL string) Alerter {
+	if webhookURL == "" {
+		return LogAlerter{}
+	}
+
+	return WebhookAlerter{
+		URL:    webhookURL,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type LogAlerter struct{}
+
+func (LogAlerter) Alert(ctx context.Context, message string) error {
+	slog.ErrorContext(ctx, "Alert", "message", message)
+	return nil
+}
+
+// WebhookAlerter posts the alerts as {"text": message}, which Slack and
+// Discord compatible webhooks accept
+type WebhookAlerter struct {
+	URL    string
+	Client *http.Client
+}
+
+func (a WebhookAlerter) Alert(ctx context.Context, message string) error {
+	body, err := json.Marshal(map[string]string{"text": message})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := a.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("failed to send alert: unexpected status %d", res.StatusCode)
+	}
+
+	return nil
+}
@@ -2,129 +2,313 @@ package parser
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"strings"
+	"strconv"
 	"time"
-	"unicode/utf8"
 
 	"github.com/PuerkitoBio/goquery"
-	"github.com/dlclark/regexp2"
 	"github.com/gbandres98/wikidle2/internal/store"
 )
 
-var tokenizerRegex = regexp2.MustCompile(`(?<=>[^<>]*)(\b[\w]+\b)(?=[^<>]*<)`, regexp2.None)
-
 type Article struct {
 	ID    string
 	Title string
-	// Normalized word -> list of obscured spans
-	Tokens      map[string][]int
+	// Wikipedia revision the article was parsed from, 0 for articles parsed
+	// before revisions were recorded
+	Revision int64 `json:",omitempty"`
+	// License of the content, nil for articles parsed before licenses were
+	// recorded
+	SourceLicense *License `json:",omitempty"`
+	// Normalized word -> list of obscured spans, stored in the token index
+	Tokens      map[string][]int `json:"-"`
 	TitleTokens []string
-	// Obscured span id -> original word
-	Words          map[int]string
-	HTML           template.HTML
-	UnobscuredHTML template.HTML
-	Clues          []string
+	// Obscured span id -> original word, stored in the token index
+	Words map[int]string `json:"-"`
+	// Derived from UnobscuredHTML when the article is loaded
+	HTML template.HTML `json:"-"`
+	// Shared HTML, the obscured spans have ids but keep their words
+	UnobscuredHTML template.HTML `json:"-"`
+	// Categories of the parsed revision
+	Clues []string
+	// Titles of the articles redirecting to this one when it was parsed, which
+	// don't depend on the revision
+	Aliases []string `json:",omitempty"`
+	Quality Quality
+	// Policy the article was obscured with, nil for articles parsed before
+	// reveal policies existed
+	Reveal *RevealPolicy `json:",omitempty"`
+	// Obscured spans revealed from the start on easy difficulty
+	LeadWords []int `json:",omitempty"`
+	// Obscured spans that are never revealed on hard difficulty
+	HeadingWords []int          `json:",omitempty"`
+	Images       []ArticleImage `json:",omitempty"`
+
+	// Images to store along the article
+	media []mediaFile
 }
 
-func (p *Parser) ParseArticle(ctx context.Context, articleTitle string) error {
-	article := Article{
-		ID:          GetGameID(time.Now()),
-		Title:       articleTitle,
-		Tokens:      make(map[string][]int),
-		TitleTokens: make([]string, 0),
-		Words:       make(map[int]string),
+// SourceURL returns the Wikipedia page the article was parsed from, pointing
+// at its revision if it's known
+func (a Article) SourceURL() string {
+	if a.Revision == 0 {
+		return "https://es.wikipedia.org/wiki/" + url.PathEscape(a.Title)
+	}
+
+	return "https://es.wikipedia.org/w/index.php?" + url.Values{
+		"title": {a.Title},
+		"oldid": {strconv.FormatInt(a.Revision, 10)},
+	}.Encode()
+}
+
+// HistoryURL returns the history of the Wikipedia page, listing the authors of
+// the article
+func (a Article) HistoryURL() string {
+	return "https://es.wikipedia.org/w/index.php?" + url.Values{
+		"title":  {a.Title},
+		"action": {"history"},
+	}.Encode()
+}
+
+// License returns the license of the article content
+func (a Article) License() License {
+	if a.SourceLicense == nil {
+		return defaultLicense
 	}
 
-	related, err := p.parseRelated(articleTitle)
+	return *a.SourceLicense
+}
+
+// RevealPolicy returns the policy the article was obscured with
+func (a Article) RevealPolicy() RevealPolicy {
+	if a.Reveal == nil {
+		return legacyRevealPolicy
+	}
+
+	return *a.Reveal
+}
+
+// ParseArticle parses an article, checks it against the quality thresholds and
+// stores it as the article of the day. The article is parsed at the given
+// revision, or at its current one if revision is 0. The title can be left
+// empty to use the article of the revision.
+func (p *Parser) ParseArticle(ctx context.Context, articleTitle string, revision int64) error {
+	err := p.parseArticle(ctx, articleTitle, revision)
+
+	switch {
+	case err == nil:
+		parses.WithLabelValues("success").Inc()
+		lastSuccess.SetToCurrentTime()
+	case errors.Is(err, ErrArticleRejected):
+		parses.WithLabelValues("rejected").Inc()
+	default:
+		parses.WithLabelValues("error").Inc()
+	}
+
+	return err
+}
+
+func (p *Parser) parseArticle(ctx context.Context, articleTitle string, revision int64) error {
+	article, err := p.Parse(ctx, articleTitle, revision)
 	if err != nil {
 		return err
 	}
 
-	article.Clues = related
+	slog.InfoContext(ctx, "Measured article quality", "title", article.Title, "quality", article.Quality)
 
-	res, err := http.Get("https://es.wikipedia.org/api/rest_v1/page/html/" + url.PathEscape(article.Title))
+	err = p.config.Quality.Check(article.Quality)
 	if err != nil {
-		return err
+		return fmt.Errorf("article %q: %w", article.Title, err)
 	}
-	defer res.Body.Close()
 
-	bodyBytes, err := io.ReadAll(res.Body)
+	params, err := encodeArticle(article)
 	if err != nil {
 		return err
 	}
 
-	newBody, err := tokenizerRegex.Replace(string(bodyBytes), `<span class="obscured">$1</span>`, -1, -1)
+	err = p.db.DeleteMediaByGameID(ctx, article.ID)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to delete old media: %w", err)
+	}
+
+	for _, media := range article.media {
+		err = p.db.SaveMedia(ctx, store.SaveMediaParams{
+			GameID:      article.ID,
+			Name:        media.Name,
+			ContentType: media.ContentType,
+			Data:        media.Data,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save media %s: %w", media.Name, err)
+		}
 	}
 
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(newBody))
+	err = p.db.SaveArticle(ctx, params)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to save article: %w", err)
 	}
 
-	doc.Find("*").Each(func(i int, s *goquery.Selection) {
-		s.RemoveAttr("data-mw")
-		s.RemoveAttr("id")
-	})
+	slog.InfoContext(ctx, "Successfully parsed article", "title", article.Title, "revision", article.Revision, "game_id", article.ID)
+	return nil
+}
 
-	doc.Find("audio").Each(func(i int, s *goquery.Selection) {
-		s.Remove()
-	})
+// ParseNextArticle parses articles from the queue until one of them is parsed
+// and passes the quality thresholds, and returns its title. Articles that are
+// rejected or fail for good are skipped. On a transient error the article is
+// put back in the queue and the error returned, so an outage doesn't use up
+// the queue.
+func (p *Parser) ParseNextArticle(ctx context.Context) (string, error) {
+	for i := 0; i < p.config.MaxCandidates; i++ {
+		entry, queued, err := p.GetArticleFromQueue(ctx)
+		if err != nil {
+			return "", err
+		}
 
-	doc.Find(".vcard").Each(func(i int, s *goquery.Selection) {
-		s.Remove()
-	})
+		err = p.ParseArticle(ctx, entry.Title, 0)
+		if err == nil {
+			return entry.Title, nil
+		}
 
-	doc.Find(".mw-authority-control").Each(func(i int, s *goquery.Selection) {
-		s.Remove()
-	})
+		if !isTransient(err) && ctx.Err() == nil {
+			slog.WarnContext(ctx, "Skipping article", "title", entry.Title, "error", err)
+			continue
+		}
 
-	doc.Find("title").Each(func(i int, s *goquery.Selection) {
-		s.Remove()
-	})
+		if queued {
+			// The context may be done, the article must not be lost anyway
+			requeueErr := p.requeueArticle(context.WithoutCancel(ctx), entry)
+			if requeueErr != nil {
+				return "", errors.Join(fmt.Errorf("failed to parse %q: %w", entry.Title, err), requeueErr)
+			}
+		}
 
-	title := `<h1>`
-	titleWords := strings.Split(article.Title, " ")
-	for _, word := range titleWords {
-		title += `<span class="obscured">` + word + `</span> `
-		article.TitleTokens = append(article.TitleTokens, Normalize(word))
+		return "", fmt.Errorf("failed to parse %q: %w", entry.Title, err)
 	}
-	title += `</h1>`
 
-	doc.Find("section").First().BeforeHtml(title)
+	return "", fmt.Errorf("no suitable article found after %d candidates", p.config.MaxCandidates)
+}
+
+// Parse parses an article at the given revision, or at its current one if
+// revision is 0. The body and clues come from the revision, the aliases are
+// the current redirects to the article.
+func (p *Parser) Parse(ctx context.Context, articleTitle string, revision int64) (Article, error) {
+	return p.parse(ctx, articleTitle, revision, true)
+}
+
+// parse parses the article, downloading and obscuring its images only if
+// withImages is set
+func (p *Parser) parse(ctx context.Context, articleTitle string, revision int64, withImages bool) (Article, error) {
+	var pinnedTitle string
+	if revision != 0 {
+		var err error
+		pinnedTitle, err = revisionTitle(ctx, revision)
+		if err != nil {
+			return Article{}, err
+		}
+
+		if articleTitle == "" {
+			articleTitle = pinnedTitle
+		}
+	}
 
-	unobscuredHTML, err := doc.Html()
+	// Redirects and differently written titles would otherwise end up as the
+	// title to guess
+	canonical, err := ResolveTitle(ctx, articleTitle)
 	if err != nil {
-		return err
+		return Article{}, err
 	}
 
-	article.UnobscuredHTML = template.HTML(unobscuredHTML)
+	if canonical != articleTitle {
+		slog.InfoContext(ctx, "Resolved article title", "title", articleTitle, "canonical", canonical)
+		articleTitle = canonical
+	}
 
-	doc.Find("a").Each(func(i int, s *goquery.Selection) {
-		s.SetAttr("href", "javascript:void(0);")
-		s.RemoveAttr("title")
-	})
+	if revision == 0 {
+		// Fetching the current revision by id keeps the HTML consistent with
+		// the recorded revision if the article is edited meanwhile
+		revision, err = latestRevision(ctx, articleTitle)
+		if err != nil {
+			return Article{}, err
+		}
+	} else if pinnedTitle != articleTitle {
+		return Article{}, fmt.Errorf("%w: revision %d belongs to %q, not %q", ErrArticleRejected, revision, pinnedTitle, articleTitle)
+	}
+
+	reveal := p.config.Reveal
+	article := Article{
+		ID:          GetGameID(time.Now()),
+		Title:       articleTitle,
+		Revision:    revision,
+		Tokens:      make(map[string][]int),
+		TitleTokens: make([]string, 0),
+		Words:       make(map[int]string),
+		Reveal:      &reveal,
+	}
 
-	doc.Find("img").Each(func(i int, s *goquery.Selection) {
-		height := s.AttrOr("height", "400")
-		width := s.AttrOr("width", "400")
+	// Redirects point at the page, not at a revision, so the aliases are
+	// always the current ones
+	aliases, err := p.parseAliases(ctx, articleTitle)
+	if err != nil {
+		return Article{}, err
+	}
+
+	article.Aliases = aliases
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://es.wikipedia.org/api/rest_v1/page/html/"+url.PathEscape(article.Title)+"/"+strconv.FormatInt(article.Revision, 10), nil)
+	if err != nil {
+		return Article{}, err
+	}
+
+	res, err := wikipediaClient.Do(req)
+	if err != nil {
+		return Article{}, err
+	}
+	defer res.Body.Close()
+
+	err = checkStatus(res)
+	if err != nil {
+		return Article{}, fmt.Errorf("failed to get revision %d of %q: %w", article.Revision, article.Title, err)
+	}
 
-		s.SetAttr("src", "https://placehold.co/"+width+"x"+height+"?text=?")
-		s.RemoveAttr("srcset")
+	doc, err := goquery.NewDocumentFromReader(res.Body)
+	if err != nil {
+		return Article{}, err
+	}
+
+	license := parseLicense(doc)
+	article.SourceLicense = &license
+
+	article.Clues = parseRelated(doc)
+
+	removed := Cleanup(doc.Selection, p.config.Cleanup)
+	slog.InfoContext(ctx, "Cleaned up article", "title", article.Title, "removed", formatCleanup(p.config.Cleanup, removed))
+
+	for _, node := range doc.Nodes {
+		tokenize(node)
+	}
+
+	doc.Find("*").Each(func(i int, s *goquery.Selection) {
+		s.RemoveAttr("data-mw")
+		s.RemoveAttr("id")
 	})
 
+	title, titleTokens := tokenizeTitle(article.Title, p.config.Reveal)
+	article.TitleTokens = titleTokens
+
+	doc.Find("section").First().BeforeHtml(title)
+
+	if withImages {
+		p.downloadImages(ctx, &article, doc)
+	}
+
 	doc.Find("span.obscured").Each(func(i int, s *goquery.Selection) {
 		word := Normalize(s.Text())
 
-		if IsExcludedWord(word) {
+		if p.config.Reveal.IsRevealed(word) {
 			return
 		}
 
@@ -135,25 +319,34 @@ func (p *Parser) ParseArticle(ctx context.Context, articleTitle string) error {
 		article.Words[i] = s.Text()
 
 		s.SetAttr("id", "obscured-"+fmt.Sprint(i))
-		s.SetText(strings.Repeat("#", utf8.RuneCountInString(s.Text())))
 	})
 
+	article.LeadWords = leadWords(article, doc.Selection)
+	article.HeadingWords = headingWords(doc.Selection)
+	imageCaptions(doc, article.Images)
+
+	sharedHTML, err := doc.Html()
+	if err != nil {
+		return Article{}, err
+	}
+
+	article.UnobscuredHTML = template.HTML(sharedHTML)
+
+	obscureDocument(doc, article)
+
 	html, err := doc.Html()
 	if err != nil {
-		return err
+		return Article{}, err
 	}
 
 	article.HTML = template.HTML(html)
 
-	articleJson, err := json.Marshal(article)
+	err = validateTitle(article)
 	if err != nil {
-		return err
+		return Article{}, err
 	}
 
-	log.Println("Successfully parsed article")
-	return p.db.SaveArticle(ctx, store.SaveArticleParams{
-		ID:      article.ID,
-		Content: articleJson,
-		Title:   article.Title,
-	})
+	article.Quality = measureQuality(article, doc.Selection)
+
+	return article, nil
 }
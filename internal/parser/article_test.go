@@ -0,0 +1,168 @@
+package parser
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbandres98/wikidle2/internal/store"
+)
+
+// fakeWikipedia serves every article with the parsoid_article.html fixture,
+// except the ones given a status
+type fakeWikipedia struct {
+	// Article title -> status of its HTML
+	status map[string]int
+	// Status of every action API request, OK if unset
+	apiStatus int
+}
+
+func (f fakeWikipedia) RoundTrip(r *http.Request) (*http.Response, error) {
+	query := r.URL.Query()
+	if r.Method == http.MethodPost {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		query, err = url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	status, body := http.StatusOK, ""
+	switch {
+	case f.apiStatus != 0 && r.URL.Path == "/w/api.php":
+		status = f.apiStatus
+	case strings.HasPrefix(r.URL.Path, "/api/rest_v1/page/html/"):
+		title, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/rest_v1/page/html/"), "/")
+		if s, ok := f.status[title]; ok {
+			status = s
+			break
+		}
+
+		fixture, err := os.ReadFile(filepath.Join("testdata", "parsoid_article.html"))
+		if err != nil {
+			return nil, err
+		}
+		body = string(fixture)
+	case query.Get("prop") == "pageprops":
+		pages := []string{}
+		for _, title := range strings.Split(query.Get("titles"), "|") {
+			pages = append(pages, `{"title":"`+title+`","ns":0}`)
+		}
+		body = `{"query":{"pages":[` + strings.Join(pages, ",") + `]}}`
+	case query.Get("prop") == "revisions":
+		body = `{"query":{"pages":[{"title":"` + query.Get("titles") + `","revisions":[{"revid":1}]}]}}`
+	default:
+		body = `{"query":{"pages":[{}]}}`
+	}
+
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    r,
+	}, nil
+}
+
+func newTestParser(t *testing.T, wikipedia fakeWikipedia) (*Parser, *store.DB) {
+	t.Helper()
+
+	transport := http.DefaultTransport
+	http.DefaultTransport = wikipedia
+	t.Cleanup(func() { http.DefaultTransport = transport })
+
+	db, err := store.NewDB(context.Background(), "sqlite3", "file:"+filepath.Join(t.TempDir(), "wikidle.db"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	config := DefaultConfig()
+	config.Quality = QualityThresholds{MinWordCount: 10, MaxTableFraction: 1}
+	config.Images.MaxImages = 0
+
+	return New(db, config), db
+}
+
+func queueArticles(t *testing.T, db *store.DB, titles ...string) {
+	t.Helper()
+
+	for _, title := range titles {
+		err := db.AddArticleToQueue(context.Background(), store.AddArticleToQueueParams{Title: title})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseNextArticleSkipsFailedArticle(t *testing.T) {
+	ctx := context.Background()
+	p, db := newTestParser(t, fakeWikipedia{status: map[string]int{"Roto": http.StatusNotFound}})
+
+	// The newest undated article is popped first
+	queueArticles(t, db, "Mercurio (planeta)", "Roto")
+
+	title, err := p.ParseNextArticle(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if title != "Mercurio (planeta)" {
+		t.Errorf("parsed %q, want the next queued article", title)
+	}
+
+	article, err := LoadArticleIndex(ctx, db.Queries, GetGameID(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Title != "Mercurio (planeta)" {
+		t.Errorf("stored %q, want the next queued article", article.Title)
+	}
+
+	count, err := db.CountQueueArticles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 0 {
+		t.Errorf("%d articles left in the queue, want none", count)
+	}
+}
+
+func TestParseNextArticleKeepsArticleOnOutage(t *testing.T) {
+	ctx := context.Background()
+	p, db := newTestParser(t, fakeWikipedia{status: map[string]int{"Roto": http.StatusServiceUnavailable}})
+
+	queueArticles(t, db, "Mercurio (planeta)", "Roto")
+
+	_, err := p.ParseNextArticle(ctx)
+	if !isTransient(err) {
+		t.Fatalf("got %v, want a transient error", err)
+	}
+
+	_, err = LoadArticleIndex(ctx, db.Queries, GetGameID(time.Now()))
+	if err != sql.ErrNoRows {
+		t.Errorf("got %v, want no article", err)
+	}
+
+	titles, err := db.GetQueueTitles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(titles) != 2 {
+		t.Errorf("queue has %v, want both articles", titles)
+	}
+}
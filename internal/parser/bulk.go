@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gbandres98/wikidle2/internal/store"
+)
+
+// Statuses of a candidate article
+const (
+	CandidateAccepted = "accepted"
+	CandidateRejected = "rejected"
+	CandidateError    = "error"
+)
+
+// Candidate is the result of parsing an article ahead of time
+type Candidate struct {
+	Title    string
+	Revision int64
+	Status   string
+	// Nil if the article failed before its quality was measured
+	Quality *Quality
+	Err     error
+}
+
+// ParseCandidates parses the articles with the given number of workers and
+// stores them as candidates with their quality, without making any of them
+// the article of the day. Articles that fail to parse are stored too, with
+// their error. The candidates are returned in the order of the titles.
+//
+// Only the quality of the candidates is kept, so their images are not
+// downloaded. Candidates are parsed again in full when they are picked as the
+// article of the day.
+func (p *Parser) ParseCandidates(ctx context.Context, titles []string, workers int) ([]Candidate, error) {
+	candidates := make([]Candidate, len(titles))
+	indexes := make(chan int)
+
+	var mu sync.Mutex
+	var saveErrors []error
+
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				candidates[i] = p.parseCandidate(ctx, titles[i])
+
+				err := p.saveCandidate(ctx, candidates[i])
+				if err != nil {
+					mu.Lock()
+					saveErrors = append(saveErrors, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range titles {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return candidates, errors.Join(saveErrors...)
+}
+
+func (p *Parser) parseCandidate(ctx context.Context, title string) Candidate {
+	candidate := Candidate{Title: title}
+
+	article, err := p.parse(ctx, title, 0, false)
+	if err == nil {
+		candidate.Title = article.Title
+		candidate.Revision = article.Revision
+		candidate.Quality = &article.Quality
+
+		err = p.config.Quality.Check(article.Quality)
+	}
+
+	switch {
+	case err == nil:
+		candidate.Status = CandidateAccepted
+	case errors.Is(err, ErrArticleRejected):
+		candidate.Status = CandidateRejected
+	default:
+		candidate.Status = CandidateError
+	}
+	candidate.Err = err
+
+	slog.InfoContext(ctx, "Parsed candidate article", "title", candidate.Title, "status", candidate.Status, "error", err)
+
+	return candidate
+}
+
+func (p *Parser) saveCandidate(ctx context.Context, candidate Candidate) error {
+	quality, err := json.Marshal(candidate.Quality)
+	if err != nil {
+		return fmt.Errorf("failed to encode quality of %q: %w", candidate.Title, err)
+	}
+
+	var candidateError sql.NullString
+	if candidate.Err != nil {
+		candidateError = sql.NullString{String: candidate.Err.Error(), Valid: true}
+	}
+
+	err = p.db.SaveArticleCandidate(ctx, store.SaveArticleCandidateParams{
+		Title:    candidate.Title,
+		Revision: candidate.Revision,
+		ParsedAt: time.Now().UTC().Truncate(time.Second),
+		Status:   candidate.Status,
+		Quality:  quality,
+		Error:    candidateError,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save candidate %q: %w", candidate.Title, err)
+	}
+
+	return nil
+}
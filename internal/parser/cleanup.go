@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// SanitizerStage removes the elements matching any of its selectors from the
+// parsed article
+type SanitizerStage struct {
+	Name      string
+	Selectors []string
+}
+
+func DefaultCleanupStages() []SanitizerStage {
+	return []SanitizerStage{
+		{Name: "title", Selectors: []string{"title"}},
+		{Name: "scripts", Selectors: []string{"script", "style", "noscript"}},
+		{Name: "media", Selectors: []string{"audio", "video"}},
+		{Name: "vcard", Selectors: []string{".vcard"}},
+		{Name: "authority-control", Selectors: []string{".mw-authority-control"}},
+		{Name: "references", Selectors: []string{"sup.reference", "sup.mw-ref", ".mw-references-wrap", "ol.references"}},
+		{Name: "navboxes", Selectors: []string{".navbox", ".navbox-styles", ".navbox-inner", "[role=navigation]"}},
+		{Name: "coordinates", Selectors: []string{"#coordinates", ".geo-default", ".geo-nondefault", ".geo-multi-punct"}},
+		{Name: "hatnotes", Selectors: []string{".hatnote", ".dablink", ".rellink"}},
+		{Name: "edit-links", Selectors: []string{".mw-editsection"}},
+	}
+}
+
+// WithoutStages returns the stages whose name is not in names
+func WithoutStages(stages []SanitizerStage, names []string) []SanitizerStage {
+	filtered := []SanitizerStage{}
+
+	for _, stage := range stages {
+		skip := false
+		for _, name := range names {
+			if strings.EqualFold(stage.Name, name) {
+				skip = true
+			}
+		}
+
+		if !skip {
+			filtered = append(filtered, stage)
+		}
+	}
+
+	return filtered
+}
+
+// Cleanup runs the sanitizer stages in order over the document and returns the
+// number of elements each stage removed
+func Cleanup(doc *goquery.Selection, stages []SanitizerStage) map[string]int {
+	removed := make(map[string]int)
+
+	for _, stage := range stages {
+		matches := doc.Find(strings.Join(stage.Selectors, ", "))
+		removed[stage.Name] += matches.Length()
+		matches.Remove()
+	}
+
+	return removed
+}
+
+func formatCleanup(stages []SanitizerStage, removed map[string]int) string {
+	parts := []string{}
+	for _, stage := range stages {
+		if removed[stage.Name] > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%d", stage.Name, removed[stage.Name]))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
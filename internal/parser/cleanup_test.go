@@ -0,0 +1,193 @@
+package parser
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func loadFixture(t testing.TB, name string) *goquery.Document {
+	t.Helper()
+
+	file, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	doc, err := goquery.NewDocumentFromReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return doc
+}
+
+// assertGolden compares got with a golden file in testdata, rewriting the file
+// instead when the tests run with -update
+func assertGolden(t *testing.T, name string, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		err := os.WriteFile(path, []byte(got), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != string(want) {
+		t.Errorf("output doesn't match %s, run the tests with -update to see the diff in git\ngot:\n%s", path, got)
+	}
+}
+
+func defaultStage(t *testing.T, name string) SanitizerStage {
+	t.Helper()
+
+	for _, stage := range DefaultCleanupStages() {
+		if stage.Name == name {
+			return stage
+		}
+	}
+
+	t.Fatalf("no default stage %q", name)
+	return SanitizerStage{}
+}
+
+func TestCleanupStages(t *testing.T) {
+	tests := []struct {
+		stage   string
+		removed int
+	}{
+		{stage: "title", removed: 1},
+		{stage: "scripts", removed: 4},
+		{stage: "media", removed: 2},
+		{stage: "vcard", removed: 1},
+		{stage: "authority-control", removed: 1},
+		{stage: "references", removed: 4},
+		{stage: "navboxes", removed: 3},
+		{stage: "coordinates", removed: 3},
+		{stage: "hatnotes", removed: 2},
+		{stage: "edit-links", removed: 1},
+	}
+
+	if len(tests) != len(DefaultCleanupStages()) {
+		t.Fatalf("%d stages tested, %d default stages", len(tests), len(DefaultCleanupStages()))
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.stage, func(t *testing.T) {
+			stage := defaultStage(t, tt.stage)
+			doc := loadFixture(t, "parsoid_article.html")
+
+			removed := Cleanup(doc.Selection, []SanitizerStage{stage})
+
+			if removed[tt.stage] != tt.removed {
+				t.Errorf("removed %d elements, want %d", removed[tt.stage], tt.removed)
+			}
+
+			if left := doc.Find(strings.Join(stage.Selectors, ", ")).Length(); left != 0 {
+				t.Errorf("%d elements still match the stage", left)
+			}
+
+			html, err := doc.Html()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertGolden(t, "cleanup_"+tt.stage+".golden.html", html)
+		})
+	}
+}
+
+func TestCleanupPipelines(t *testing.T) {
+	tests := []struct {
+		name   string
+		stages []SanitizerStage
+		// Elements removed by some of the stages
+		removed map[string]int
+		// Selectors that must still match after the cleanup
+		kept []string
+	}{
+		{
+			name:    "default",
+			stages:  DefaultCleanupStages(),
+			removed: map[string]int{"scripts": 4, "references": 4},
+			kept:    []string{"p", "figure", "h2"},
+		},
+		{
+			name:    "skip",
+			stages:  WithoutStages(DefaultCleanupStages(), []string{"References", "hatnotes"}),
+			removed: map[string]int{"references": 0, "hatnotes": 0},
+			kept:    []string{"sup.reference", "ol.references", ".hatnote", ".dablink"},
+		},
+		{
+			name: "custom",
+			stages: append(DefaultCleanupStages(), SanitizerStage{
+				Name:      "custom",
+				Selectors: []string{"figure", "th.cabecera"},
+			}),
+			// The infobox heading goes first with the vcard stage
+			removed: map[string]int{"vcard": 1, "custom": 1},
+			kept:    []string{"p", "h2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := loadFixture(t, "parsoid_article.html")
+
+			removed := Cleanup(doc.Selection, tt.stages)
+
+			for _, stage := range tt.stages {
+				if left := doc.Find(strings.Join(stage.Selectors, ", ")).Length(); left != 0 {
+					t.Errorf("stage %s: %d elements still match", stage.Name, left)
+				}
+			}
+
+			for _, selector := range tt.kept {
+				if doc.Find(selector).Length() == 0 {
+					t.Errorf("%s was removed", selector)
+				}
+			}
+
+			for stage, want := range tt.removed {
+				if removed[stage] != want {
+					t.Errorf("stage %s removed %d elements, want %d", stage, removed[stage], want)
+				}
+			}
+
+			html, err := doc.Html()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertGolden(t, "cleanup_pipeline_"+tt.name+".golden.html", html)
+		})
+	}
+}
+
+func TestWithoutStages(t *testing.T) {
+	stages := WithoutStages(DefaultCleanupStages(), []string{"scripts", "EDIT-LINKS", "unknown"})
+
+	if len(stages) != len(DefaultCleanupStages())-2 {
+		t.Fatalf("got %d stages, want %d", len(stages), len(DefaultCleanupStages())-2)
+	}
+
+	for _, stage := range stages {
+		if stage.Name == "scripts" || stage.Name == "edit-links" {
+			t.Errorf("stage %s was not skipped", stage.Name)
+		}
+	}
+}
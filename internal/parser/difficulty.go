@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Minimum number of times a word has to appear in the article for it to be
+// revealed in the lead paragraph on easy difficulty
+const leadWordMinCount = 3
+
+// spanIDs returns the obscured span ids inside a selection
+func spanIDs(s *goquery.Selection) []int {
+	ids := []int{}
+
+	s.Find(`span.obscured[id^="obscured-"]`).Each(func(i int, span *goquery.Selection) {
+		id, err := strconv.Atoi(strings.TrimPrefix(span.AttrOr("id", ""), "obscured-"))
+		if err != nil {
+			return
+		}
+
+		ids = append(ids, id)
+	})
+
+	return ids
+}
+
+// leadWords returns the obscured spans of the lead paragraph that are common
+// in the article and not part of the title
+func leadWords(article Article, doc *goquery.Selection) []int {
+	lead := doc.Find("section").First().ChildrenFiltered("p").FilterFunction(func(i int, s *goquery.Selection) bool {
+		return strings.TrimSpace(s.Text()) != ""
+	}).First()
+
+	titleTokens := make(map[string]bool)
+	for _, token := range article.TitleTokens {
+		titleTokens[token] = true
+	}
+
+	ids := []int{}
+	for _, id := range spanIDs(lead) {
+		word := Normalize(article.Words[id])
+
+		if titleTokens[word] || len(article.Tokens[word]) < leadWordMinCount {
+			continue
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// headingWords returns the obscured spans inside section headings and image
+// captions, which stay hidden on hard difficulty
+func headingWords(doc *goquery.Selection) []int {
+	return spanIDs(doc.Find("h2, h3, h4, h5, h6, figcaption"))
+}
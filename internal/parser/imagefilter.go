@@ -0,0 +1,211 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+
+	_ "image/gif"
+)
+
+type ImageFilter string
+
+const (
+	ImageBlur        ImageFilter = "blur"
+	ImagePixelate    ImageFilter = "pixelate"
+	ImageSilhouette  ImageFilter = "silhouette"
+	ImagePlaceholder ImageFilter = "placeholder"
+)
+
+func ParseImageFilter(s string) (ImageFilter, error) {
+	switch filter := ImageFilter(s); filter {
+	case ImageBlur, ImagePixelate, ImageSilhouette, ImagePlaceholder:
+		return filter, nil
+	}
+
+	return "", fmt.Errorf("unknown image filter %q", s)
+}
+
+var placeholderColor = color.RGBA{R: 0xcc, G: 0xcc, B: 0xcc, A: 0xff}
+
+// Number of cells the longest side of an image is split in when blurring or
+// pixelating it, doubled on each level
+const obscuredImageCells = 8
+
+// obscureImageLevel applies a filter to an image and returns it encoded, along
+// with its content type. Level 0 is the most obscured one. Silhouettes and
+// placeholders can't be made less obscure, so higher levels are pixelated.
+func obscureImageLevel(img image.Image, filter ImageFilter, level int) ([]byte, string, error) {
+	if level > 0 && filter != ImageBlur {
+		filter = ImagePixelate
+	}
+
+	cells := obscuredImageCells << level
+
+	var buf bytes.Buffer
+
+	switch filter {
+	case ImageBlur:
+		err := jpeg.Encode(&buf, blur(img, cells), &jpeg.Options{Quality: 70})
+		return buf.Bytes(), "image/jpeg", err
+	case ImagePixelate:
+		err := jpeg.Encode(&buf, pixelate(img, cells), &jpeg.Options{Quality: 70})
+		return buf.Bytes(), "image/jpeg", err
+	case ImageSilhouette:
+		err := png.Encode(&buf, silhouette(img))
+		return buf.Bytes(), "image/png", err
+	default:
+		return placeholderImage(img.Bounds().Dx(), img.Bounds().Dy())
+	}
+}
+
+// placeholderImage returns a flat image, used when an image can't be
+// downloaded or decoded
+func placeholderImage(width, height int) ([]byte, string, error) {
+	width, height = max(width, 1), max(height, 1)
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: placeholderColor}, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), "image/png", err
+}
+
+// shrink averages the image into cells of the given size
+func shrink(img image.Image, cell int) *image.RGBA {
+	b := img.Bounds()
+	w, h := (b.Dx()+cell-1)/cell, (b.Dy()+cell-1)/cell
+	small := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var r, g, bl, a, n uint32
+
+			for sy := y * cell; sy < min((y+1)*cell, b.Dy()); sy++ {
+				for sx := x * cell; sx < min((x+1)*cell, b.Dx()); sx++ {
+					pr, pg, pb, pa := img.At(b.Min.X+sx, b.Min.Y+sy).RGBA()
+					r, g, bl, a, n = r+pr, g+pg, bl+pb, a+pa, n+1
+				}
+			}
+
+			small.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+
+	return small
+}
+
+// cellSize returns a cell size that splits the longest side of the image in
+// the given number of cells
+func cellSize(img image.Image, cells int) int {
+	b := img.Bounds()
+	return max(max(b.Dx(), b.Dy())/max(cells, 1), 1)
+}
+
+func pixelate(img image.Image, cells int) image.Image {
+	cell := cellSize(img, cells)
+	small := shrink(img, cell)
+
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			out.SetRGBA(x, y, small.RGBAAt(x/cell, y/cell))
+		}
+	}
+
+	return out
+}
+
+// blur shrinks the image and scales it back up with bilinear interpolation
+func blur(img image.Image, cells int) image.Image {
+	cell := cellSize(img, cells)
+	small := shrink(img, cell)
+	sb := small.Bounds()
+
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		fy := max(float64(y)/float64(cell)-0.5, 0)
+		y0 := min(int(fy), sb.Dy()-1)
+		y1 := min(y0+1, sb.Dy()-1)
+		dy := fy - float64(y0)
+
+		for x := 0; x < b.Dx(); x++ {
+			fx := max(float64(x)/float64(cell)-0.5, 0)
+			x0 := min(int(fx), sb.Dx()-1)
+			x1 := min(x0+1, sb.Dx()-1)
+			dx := fx - float64(x0)
+
+			c00, c10 := small.RGBAAt(x0, y0), small.RGBAAt(x1, y0)
+			c01, c11 := small.RGBAAt(x0, y1), small.RGBAAt(x1, y1)
+
+			lerp := func(a, b, c, d uint8) uint8 {
+				top := float64(a)*(1-dx) + float64(b)*dx
+				bottom := float64(c)*(1-dx) + float64(d)*dx
+				return uint8(top*(1-dy) + bottom*dy)
+			}
+
+			out.SetRGBA(x, y, color.RGBA{
+				R: lerp(c00.R, c10.R, c01.R, c11.R),
+				G: lerp(c00.G, c10.G, c01.G, c11.G),
+				B: lerp(c00.B, c10.B, c01.B, c11.B),
+				A: lerp(c00.A, c10.A, c01.A, c11.A),
+			})
+		}
+	}
+
+	return out
+}
+
+// silhouette paints every pixel darker than the image average, or every
+// opaque pixel on transparent images, with a flat color
+func silhouette(img image.Image) image.Image {
+	b := img.Bounds()
+
+	var total, transparent uint64
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			total += uint64(luminance(img.At(x, y)))
+			if _, _, _, a := img.At(x, y).RGBA(); a < 0x8000 {
+				transparent++
+			}
+		}
+	}
+
+	pixels := uint64(max(b.Dx()*b.Dy(), 1))
+	mean := uint32(total / pixels)
+	hasAlpha := transparent > 0
+
+	fill := color.RGBA{R: 0x44, G: 0x44, B: 0x44, A: 0xff}
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(out, out.Bounds(), &image.Uniform{C: placeholderColor}, image.Point{}, draw.Src)
+
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			c := img.At(b.Min.X+x, b.Min.Y+y)
+			_, _, _, a := c.RGBA()
+
+			if (hasAlpha && a >= 0x8000) || (!hasAlpha && luminance(c) < mean) {
+				out.SetRGBA(x, y, fill)
+			}
+		}
+	}
+
+	return out
+}
+
+func luminance(c color.Color) uint32 {
+	r, g, b, _ := c.RGBA()
+	return (299*r + 587*g + 114*b) / 1000
+}
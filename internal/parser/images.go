@@ -0,0 +1,228 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/google/uuid"
+)
+
+// MediaPath is the path the article images are served from
+const MediaPath = "/media/"
+
+const userAgent = "wikidle (https://github.com/gbandres98/wikidle2)"
+
+type ImageConfig struct {
+	Filter ImageFilter
+	// Images past this number are replaced with placeholders
+	MaxImages int
+	// Images bigger than this are replaced with placeholders
+	MaxBytes int64
+	// Number of obscured copies of each image, from most to least obscured,
+	// revealed as the game advances
+	Levels int
+}
+
+func DefaultImageConfig() ImageConfig {
+	return ImageConfig{
+		Filter:    ImageBlur,
+		MaxImages: 40,
+		MaxBytes:  5 << 20,
+		Levels:    1,
+	}
+}
+
+type mediaFile struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+type ArticleImage struct {
+	// Local URLs of the obscured copies, from most to least obscured
+	Levels []string
+	// Obscured spans of the image caption
+	Caption []int  `json:",omitempty"`
+	Width   string `json:",omitempty"`
+	Height  string `json:",omitempty"`
+}
+
+func ImageID(index int) string {
+	return fmt.Sprintf("image-%d", index)
+}
+
+// mediaURL returns the local URL of a media file. Browsers cache media for a
+// day, so every name has a uuid and a reparsed article never shows the images
+// of the previous one.
+func mediaURL(gameID string, name string) string {
+	return MediaPath + gameID + "/" + name
+}
+
+// downloadImages stores a local copy of every image in the document and
+// points them to it. The obscured copies are applied later with obscureImages,
+// once the unobscured HTML has been rendered.
+func (p *Parser) downloadImages(ctx context.Context, article *Article, doc *goquery.Document) {
+	article.Images = []ArticleImage{}
+	placeholders := make(map[string]string)
+
+	doc.Find("img").Each(func(i int, s *goquery.Selection) {
+		width, _ := strconv.Atoi(s.AttrOr("width", "400"))
+		height, _ := strconv.Atoi(s.AttrOr("height", "400"))
+
+		entry := ArticleImage{
+			Width:  s.AttrOr("width", ""),
+			Height: s.AttrOr("height", ""),
+		}
+		defer func() {
+			article.Images = append(article.Images, entry)
+		}()
+
+		s.RemoveAttr("srcset")
+
+		var img image.Image
+		if i < p.config.Images.MaxImages {
+			original, contentType, err := p.downloadImage(ctx, s.AttrOr("src", ""))
+			if err != nil {
+				slog.WarnContext(ctx, "failed to download image", "image", i, "title", article.Title, "error", err)
+			} else {
+				name := fmt.Sprintf("%d-%s%s", i, uuid.NewString(), imageExtension(contentType))
+				article.media = append(article.media, mediaFile{
+					Name:        name,
+					ContentType: contentType,
+					Data:        original,
+				})
+				s.SetAttr("src", mediaURL(article.ID, name))
+
+				img, _, err = image.Decode(bytes.NewReader(original))
+				if err != nil {
+					slog.WarnContext(ctx, "failed to decode image", "image", i, "title", article.Title, "error", err)
+				}
+			}
+		}
+
+		if img == nil {
+			// Every undecodable image of the same size shares a placeholder
+			size := fmt.Sprintf("%dx%d", width, height)
+			if name, ok := placeholders[size]; ok {
+				entry.Levels = []string{mediaURL(article.ID, name)}
+				return
+			}
+
+			data, contentType, err := placeholderImage(width, height)
+			if err != nil {
+				slog.WarnContext(ctx, "failed to create image placeholder", "image", i, "title", article.Title, "error", err)
+				return
+			}
+
+			name := "placeholder-" + size + "-" + uuid.NewString() + ".png"
+			placeholders[size] = name
+			article.media = append(article.media, mediaFile{Name: name, ContentType: contentType, Data: data})
+			entry.Levels = []string{mediaURL(article.ID, name)}
+			return
+		}
+
+		for level := 0; level < max(p.config.Images.Levels, 1); level++ {
+			data, contentType, err := obscureImageLevel(img, p.config.Images.Filter, level)
+			if err != nil {
+				slog.WarnContext(ctx, "failed to obscure image", "image", i, "title", article.Title, "error", err)
+				return
+			}
+
+			name := fmt.Sprintf("%d-obscured-%d-%s%s", i, level, uuid.NewString(), imageExtension(contentType))
+			article.media = append(article.media, mediaFile{Name: name, ContentType: contentType, Data: data})
+			entry.Levels = append(entry.Levels, mediaURL(article.ID, name))
+		}
+	})
+}
+
+// obscureImages points the images of the document to their most obscured
+// copies
+func obscureImages(doc *goquery.Document, images []ArticleImage) {
+	doc.Find("img").Each(func(i int, s *goquery.Selection) {
+		if i >= len(images) || len(images[i].Levels) == 0 {
+			s.RemoveAttr("src")
+			return
+		}
+
+		s.SetAttr("id", ImageID(i))
+		s.SetAttr("src", images[i].Levels[0])
+	})
+}
+
+// imageCaptions stores the obscured spans of each image caption
+func imageCaptions(doc *goquery.Document, images []ArticleImage) {
+	doc.Find("img").Each(func(i int, s *goquery.Selection) {
+		if i >= len(images) {
+			return
+		}
+
+		images[i].Caption = spanIDs(s.Closest("figure").Find("figcaption"))
+	})
+}
+
+func (p *Parser) downloadImage(ctx context.Context, src string) ([]byte, string, error) {
+	if strings.HasPrefix(src, "//") {
+		src = "https:" + src
+	}
+
+	if !strings.HasPrefix(src, "https://") {
+		return nil, "", fmt.Errorf("unsupported image source %q", src)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	res, err := wikipediaClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, p.config.Images.MaxBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if int64(len(data)) > p.config.Images.MaxBytes {
+		return nil, "", fmt.Errorf("image is bigger than %d bytes", p.config.Images.MaxBytes)
+	}
+
+	contentType := res.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	return data, contentType, nil
+}
+
+func imageExtension(contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "image/jpeg"):
+		return ".jpg"
+	case strings.HasPrefix(contentType, "image/png"):
+		return ".png"
+	case strings.HasPrefix(contentType, "image/gif"):
+		return ".gif"
+	case strings.HasPrefix(contentType, "image/svg"):
+		return ".svg"
+	case strings.HasPrefix(contentType, "image/webp"):
+		return ".webp"
+	}
+
+	return ""
+}
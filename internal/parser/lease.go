@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gbandres98/wikidle2/internal/store"
+)
+
+// lease is a named lock stored in the database that expires unless its holder
+// renews it, so a crashed instance can't keep it forever
+type lease struct {
+	db       *store.Queries
+	name     string
+	holder   string
+	duration time.Duration
+}
+
+// acquire takes the lease, or extends it if it's already held by this holder.
+// It returns false when another holder has an unexpired lease.
+func (l *lease) acquire(ctx context.Context) (bool, error) {
+	// Times are truncated so SQLite, which stores them as text, compares them
+	// correctly
+	now := time.Now().UTC().Truncate(time.Second)
+
+	acquired, err := l.db.AcquireLease(ctx, store.AcquireLeaseParams{
+		Name:      l.name,
+		Holder:    l.holder,
+		ExpiresAt: now.Add(l.duration),
+		Now:       now,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease %s: %w", l.name, err)
+	}
+
+	return acquired > 0, nil
+}
+
+// keep renews the lease until the context is done, and calls lost if it's
+// taken by another holder
+func (l *lease) keep(ctx context.Context, lost func()) {
+	ticker := time.NewTicker(l.duration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		acquired, err := l.acquire(ctx)
+		if err != nil {
+			slog.WarnContext(ctx, "failed to renew lease", "lease", l.name, "error", err)
+			continue
+		}
+
+		if !acquired {
+			slog.ErrorContext(ctx, "Lease taken by another instance", "lease", l.name)
+			lost()
+			return
+		}
+	}
+}
+
+func (l *lease) release(ctx context.Context) error {
+	err := l.db.ReleaseLease(ctx, store.ReleaseLeaseParams{Name: l.name, Holder: l.holder})
+	if err != nil {
+		return fmt.Errorf("failed to release lease %s: %w", l.name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// License is the license the content of an article is published under
+type License struct {
+	Name string
+	URL  string
+}
+
+// defaultLicense is the license of Wikipedia text, used for articles parsed
+// before licenses were recorded and for pages that don't state one
+var defaultLicense = License{
+	Name: "CC BY-SA 4.0",
+	URL:  "https://creativecommons.org/licenses/by-sa/4.0/",
+}
+
+// parseLicense reads the license linked from the head of a page
+func parseLicense(doc *goquery.Document) License {
+	href, ok := doc.Find(`head link[rel~="license"]`).First().Attr("href")
+	if !ok || href == "" {
+		return defaultLicense
+	}
+
+	if strings.HasPrefix(href, "//") {
+		href = "https:" + href
+	}
+
+	return License{Name: licenseName(href), URL: href}
+}
+
+// licenseName names Creative Commons licenses by their short name, other
+// licenses by their URL
+func licenseName(href string) string {
+	u, err := url.Parse(href)
+	if err != nil || !strings.HasSuffix(u.Host, "creativecommons.org") {
+		return href
+	}
+
+	// /licenses/by-sa/4.0/
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "licenses" {
+		return href
+	}
+
+	return "CC " + strings.ToUpper(parts[1]) + " " + parts[2]
+}
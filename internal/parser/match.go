@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Characters of the title per allowed typo in a full title guess
+const charsPerTypo = 6
+
+// MatchesTitle reports whether a full title guess names the article or one of
+// its aliases. Case, accents, punctuation, revealed tokens and disambiguators
+// are ignored, and a few typos are allowed on long titles.
+func MatchesTitle(guess string, article Article) bool {
+	policy := article.RevealPolicy()
+
+	guessKey := titleKey(guess, policy)
+	if guessKey == "" {
+		return false
+	}
+
+	titles := append([]string{article.Title}, article.Aliases...)
+	for _, title := range titles {
+		key := titleKey(title, policy)
+		if key == "" {
+			continue
+		}
+
+		if levenshtein(guessKey, key) <= utf8.RuneCountInString(key)/charsPerTypo {
+			return true
+		}
+	}
+
+	return false
+}
+
+func titleKey(title string, policy RevealPolicy) string {
+	_, tokens := tokenizeTitle(title, policy)
+	return strings.Join(tokens, " ")
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/wikidle2/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	lastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "wikidle_parser_last_success_timestamp_seconds",
+		Help: "Time of the last article stored as article of the day.",
+	})
+	parses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wikidle_parser_parses_total",
+		Help: "Articles parsed, by result (success, rejected, error).",
+	}, []string{"result"})
+	wikipediaDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "wikidle_wikipedia_request_duration_seconds",
+		Help:    "Duration of the requests to Wikipedia, by endpoint.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint"})
+	wikipediaErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wikidle_wikipedia_request_errors_total",
+		Help: "Failed requests to Wikipedia, by endpoint and status.",
+	}, []string{"endpoint", "status"})
+)
+
+// RegisterMetrics registers the parser metrics, including the length of the
+// article queue, which is read on every scrape
+func RegisterMetrics(r prometheus.Registerer, db *store.Queries) {
+	r.MustRegister(lastSuccess, parses, wikipediaDuration, wikipediaErrors)
+
+	r.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "wikidle_parser_queue_length",
+		Help: "Articles waiting in the queue.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		count, err := db.CountQueueArticles(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to count queued articles", "error", err)
+			return math.NaN()
+		}
+
+		return float64(count)
+	}))
+}
+
+// wikipediaClient is used for every request to Wikipedia, so they are measured.
+// The timeout includes the wait for the rate limit.
+var wikipediaClient = &http.Client{Transport: instrumentedTransport{}, Timeout: time.Minute}
+
+type instrumentedTransport struct{}
+
+func (instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	err := wikipediaLimiter.wait(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := wikipediaEndpoint(req.URL)
+	start := time.Now()
+
+	res, err := http.DefaultTransport.RoundTrip(req)
+	wikipediaDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		wikipediaErrors.WithLabelValues(endpoint, "error").Inc()
+		return nil, err
+	}
+
+	if res.StatusCode >= http.StatusBadRequest {
+		wikipediaErrors.WithLabelValues(endpoint, strconv.Itoa(res.StatusCode)).Inc()
+	}
+
+	return res, nil
+}
+
+func wikipediaEndpoint(u *url.URL) string {
+	switch {
+	case u.Host == "upload.wikimedia.org":
+		return "media"
+	case strings.HasPrefix(u.Path, "/api/rest_v1/"):
+		return "rest"
+	case strings.HasSuffix(u.Path, "/api.php"):
+		return "api"
+	}
+
+	return "other"
+}
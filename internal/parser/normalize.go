@@ -2,13 +2,21 @@ package parser
 
 import "strings"
 
+// diacriticsReplacer folds accented letters so guesses can be typed without
+// them. "ñ" is a letter of its own in Spanish and is kept.
+var diacriticsReplacer = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ý", "y", "ÿ", "y",
+	"ç", "c",
+)
+
 func Normalize(word string) string {
 	word = strings.ToLower(word)
 	word = strings.Trim(word, ".,;:()[]{}\"'¿?¡! ")
-	word = strings.ReplaceAll(word, "á", "a")
-	word = strings.ReplaceAll(word, "é", "e")
-	word = strings.ReplaceAll(word, "í", "i")
-	word = strings.ReplaceAll(word, "ó", "o")
-	word = strings.ReplaceAll(word, "ú", "u")
+	word = diacriticsReplacer.Replace(word)
 	return word
 }
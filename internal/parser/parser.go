@@ -6,13 +6,34 @@ import (
 	"github.com/gbandres98/wikidle2/internal/store"
 )
 
+type Config struct {
+	Quality QualityThresholds
+	Reveal  RevealPolicy
+	Images  ImageConfig
+	Cleanup []SanitizerStage
+	// Maximum number of queued articles to try before giving up on a day
+	MaxCandidates int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Quality:       DefaultQualityThresholds(),
+		Reveal:        DefaultRevealPolicy(),
+		Images:        DefaultImageConfig(),
+		Cleanup:       DefaultCleanupStages(),
+		MaxCandidates: 10,
+	}
+}
+
 type Parser struct {
-	db *store.Queries
+	db     *store.DB
+	config Config
 }
 
-func New(db *store.Queries) *Parser {
+func New(db *store.DB, config Config) *Parser {
 	return &Parser{
-		db: db,
+		db:     db,
+		config: config,
 	}
 }
 
@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var ErrArticleRejected = errors.New("article rejected")
+
+type Quality struct {
+	// Fraction of the title tokens that also appear in the article body
+	TitleTokensInBody float64
+	WordCount         int
+	// Fraction of the body text that is inside tables
+	TableFraction  float64
+	Disambiguation bool
+	List           bool
+}
+
+type QualityThresholds struct {
+	MinTitleTokensInBody float64
+	MinWordCount         int
+	MaxTableFraction     float64
+}
+
+func DefaultQualityThresholds() QualityThresholds {
+	return QualityThresholds{
+		MinTitleTokensInBody: 0.5,
+		MinWordCount:         1000,
+		MaxTableFraction:     0.4,
+	}
+}
+
+func (t QualityThresholds) Check(q Quality) error {
+	if q.Disambiguation {
+		return fmt.Errorf("%w: article is a disambiguation page", ErrArticleRejected)
+	}
+
+	if q.List {
+		return fmt.Errorf("%w: article is a list", ErrArticleRejected)
+	}
+
+	if q.WordCount < t.MinWordCount {
+		return fmt.Errorf("%w: word count %d is below %d", ErrArticleRejected, q.WordCount, t.MinWordCount)
+	}
+
+	if q.TitleTokensInBody < t.MinTitleTokensInBody {
+		return fmt.Errorf("%w: %.2f of title tokens in body is below %.2f", ErrArticleRejected, q.TitleTokensInBody, t.MinTitleTokensInBody)
+	}
+
+	if q.TableFraction > t.MaxTableFraction {
+		return fmt.Errorf("%w: table fraction %.2f is above %.2f", ErrArticleRejected, q.TableFraction, t.MaxTableFraction)
+	}
+
+	return nil
+}
+
+// measureQuality must be called once the article tokens have been indexed.
+// Whether the title can be guessed at all is checked by validateTitle before.
+func measureQuality(article Article, doc *goquery.Selection) Quality {
+	q := Quality{
+		WordCount:      len(article.Words),
+		Disambiguation: doc.Find(`link[rel="mw:PageProp/disambiguation"]`).Length() > 0,
+		List:           strings.HasPrefix(article.Title, "Anexo:"),
+	}
+
+	titleCount := make(map[string]int)
+	for _, token := range article.TitleTokens {
+		titleCount[token]++
+	}
+
+	policy := article.RevealPolicy()
+	required, inBody := 0, 0
+	for _, token := range article.TitleTokens {
+		if policy.IsRevealed(token) {
+			continue
+		}
+
+		required++
+		// The title heading is indexed as well, so only count occurrences
+		// beyond the ones in the title itself
+		if len(article.Tokens[token]) > titleCount[token] {
+			inBody++
+		}
+	}
+
+	if required > 0 {
+		q.TitleTokensInBody = float64(inBody) / float64(required)
+	}
+
+	bodyLength := len(strings.TrimSpace(doc.Find("body").Text()))
+	tableLength := 0
+	doc.Find("table").Not("table table").Each(func(i int, s *goquery.Selection) {
+		tableLength += len(strings.TrimSpace(s.Text()))
+	})
+
+	if bodyLength > 0 {
+		q.TableFraction = float64(tableLength) / float64(bodyLength)
+	}
+
+	return q
+}
+
+// isGuessableToken reports whether a normalized token is something the
+// tokenizer can emit and a player can type as a single word
+func isGuessableToken(token string) bool {
+	if token == "" {
+		return false
+	}
+
+	for _, r := range token {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r) && r != '_' {
+			return false
+		}
+	}
+
+	return true
+}
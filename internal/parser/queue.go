@@ -0,0 +1,332 @@
+package parser
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/wikidle2/internal/store"
+)
+
+const (
+	FeaturedArticlesCategory = "Categoría:Wikipedia:Artículos destacados"
+	GoodArticlesCategory     = "Categoría:Wikipedia:Artículos buenos"
+)
+
+const categoryNamespace = 14
+
+// QueueEntry is an article to add to the queue, for a given game if OnDate is
+// set
+type QueueEntry struct {
+	Title  string `json:"title"`
+	OnDate string `json:"on_date,omitempty"`
+}
+
+type wikipediaCategoryMembersResponse struct {
+	Continue struct {
+		Code string `json:"cmcontinue"`
+	} `json:"continue"`
+	Query struct {
+		Members []struct {
+			Namespace int    `json:"ns"`
+			Title     string `json:"title"`
+		} `json:"categorymembers"`
+	} `json:"query"`
+}
+
+// CategoryArticles returns the articles of a category and of its subcategories
+// down to the given depth, 0 meaning only the category itself
+func CategoryArticles(ctx context.Context, category string, depth int) ([]string, error) {
+	category = categoryTitle(category)
+
+	visited := map[string]bool{category: true}
+	seen := map[string]bool{}
+	level := []string{category}
+	articles := []string{}
+
+	for d := 0; d <= depth && len(level) > 0; d++ {
+		var next []string
+
+		for _, category := range level {
+			pages, subcategories, err := categoryMembers(ctx, category)
+			if err != nil {
+				return nil, err
+			}
+
+			slog.DebugContext(ctx, "Got category members", "category", category, "articles", len(pages), "subcategories", len(subcategories))
+			for _, page := range pages {
+				if !seen[page] {
+					seen[page] = true
+					articles = append(articles, page)
+				}
+			}
+
+			for _, subcategory := range subcategories {
+				if !visited[subcategory] {
+					visited[subcategory] = true
+					next = append(next, subcategory)
+				}
+			}
+		}
+
+		level = next
+	}
+
+	return articles, nil
+}
+
+// categoryPrefixes are the namespace prefixes Wikipedia accepts for
+// categories, in any case
+var categoryPrefixes = []string{"Categoría:", "Category:"}
+
+// categoryTitle returns the title of a category named with or without its
+// namespace prefix
+func categoryTitle(category string) string {
+	category = strings.TrimSpace(category)
+	for _, prefix := range categoryPrefixes {
+		if len(category) >= len(prefix) && strings.EqualFold(category[:len(prefix)], prefix) {
+			category = strings.TrimSpace(category[len(prefix):])
+			break
+		}
+	}
+
+	return "Categoría:" + category
+}
+
+func categoryMembers(ctx context.Context, category string) ([]string, []string, error) {
+	query := url.Values{
+		"format":        {"json"},
+		"formatversion": {"2"},
+		"origin":        {"*"},
+		"action":        {"query"},
+		"list":          {"categorymembers"},
+		"uselang":       {"content"},
+		"cmtitle":       {category},
+		"cmtype":        {"page|subcat"},
+		"cmlimit":       {"500"},
+	}
+
+	var pages, subcategories []string
+
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://es.wikipedia.org/w/api.php?"+query.Encode(), nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to build category request: %w", err)
+		}
+
+		res, err := wikipediaClient.Do(req)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get members of %s: %w", category, err)
+		}
+
+		err = checkStatus(res)
+		if err != nil {
+			res.Body.Close()
+			return nil, nil, fmt.Errorf("failed to get members of %s: %w", category, err)
+		}
+
+		var response wikipediaCategoryMembersResponse
+		err = json.NewDecoder(res.Body).Decode(&response)
+		res.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode members of %s: %w", category, err)
+		}
+
+		for _, member := range response.Query.Members {
+			if member.Namespace == categoryNamespace {
+				subcategories = append(subcategories, member.Title)
+			} else {
+				pages = append(pages, member.Title)
+			}
+		}
+
+		if response.Continue.Code == "" {
+			return pages, subcategories, nil
+		}
+
+		query.Set("cmcontinue", response.Continue.Code)
+	}
+}
+
+// ReadQueueFile reads the articles to queue from a text file with a title per
+// line, or from a CSV file with a title column and an optional onDate column.
+// Dates can be game ids (20060102) or ISO dates (2006-01-02).
+func ReadQueueFile(r io.Reader, isCSV bool) ([]QueueEntry, error) {
+	if !isCSV {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+
+		entries := []QueueEntry{}
+		for _, line := range strings.Split(string(data), "\n") {
+			if title := strings.TrimSpace(line); title != "" && !strings.HasPrefix(title, "#") {
+				entries = append(entries, QueueEntry{Title: title})
+			}
+		}
+
+		return entries, nil
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv: %w", err)
+	}
+
+	entries := []QueueEntry{}
+	for i, record := range records {
+		entry := QueueEntry{Title: strings.TrimSpace(record[0])}
+		if entry.Title == "" || (i == 0 && strings.EqualFold(entry.Title, "title")) {
+			continue
+		}
+
+		if len(record) > 1 && strings.TrimSpace(record[1]) != "" {
+			entry.OnDate, err = parseQueueDate(strings.TrimSpace(record[1]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid date for %q: %w", entry.Title, err)
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func parseQueueDate(value string) (string, error) {
+	date, err := ParseDate(value)
+	if err != nil {
+		return "", err
+	}
+
+	return GetGameID(date), nil
+}
+
+// ParseDate parses a game id (20060102) or an ISO date (2006-01-02)
+func ParseDate(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102", time.DateOnly} {
+		if date, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%q is not a date", value)
+}
+
+// EnqueueArticles adds the entries to the queue in random order, under their
+// canonical titles, skipping invalid titles and the articles that are already
+// queued or have been played. Dated entries are scheduled, and skipped if they
+// conflict with the schedule. The entries are queued in a single transaction,
+// so either all of them are queued or none is. It returns the number of
+// queued articles.
+func EnqueueArticles(ctx context.Context, db *store.DB, entries []QueueEntry) (int, error) {
+	titles := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		titles = append(titles, entry.Title)
+	}
+	slices.Sort(titles)
+	titles = slices.Compact(titles)
+
+	canonical, rejected, err := ResolveTitles(ctx, titles)
+	if err != nil {
+		return 0, err
+	}
+
+	for title, err := range rejected {
+		slog.WarnContext(ctx, "Skipping invalid article", "title", title, "error", err)
+	}
+
+	total := len(entries)
+	entries = slices.DeleteFunc(slices.Clone(entries), func(entry QueueEntry) bool {
+		_, ok := rejected[entry.Title]
+		return ok
+	})
+	for i := range entries {
+		entries[i].Title = canonical[entries[i].Title]
+	}
+
+	rand.Shuffle(len(entries), func(i, j int) { entries[i], entries[j] = entries[j], entries[i] })
+
+	// Dated entries go first, so they win over undated copies of the same
+	// title
+	slices.SortStableFunc(entries, func(a, b QueueEntry) int {
+		return strings.Compare(b.OnDate, a.OnDate)
+	})
+
+	count := 0
+	err = db.InTx(ctx, func(q *store.Queries) error {
+		queued, err := q.GetQueueTitles(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get queued articles: %w", err)
+		}
+
+		played, err := q.GetArticleTitles(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get played articles: %w", err)
+		}
+
+		known := make(map[string]bool, len(queued)+len(played))
+		for _, title := range append(queued, played...) {
+			known[title] = true
+		}
+
+		for _, entry := range entries {
+			if entry.OnDate != "" {
+				date, err := ParseDate(entry.OnDate)
+				if err != nil {
+					return fmt.Errorf("invalid date for %q: %w", entry.Title, err)
+				}
+
+				err = ScheduleArticle(ctx, q, entry.Title, date, false)
+				if errors.Is(err, ErrScheduleConflict) {
+					slog.WarnContext(ctx, "Skipping scheduled article", "title", entry.Title, "game_id", entry.OnDate, "error", err)
+					continue
+				}
+				if err != nil {
+					return err
+				}
+
+				known[entry.Title] = true
+				count++
+				continue
+			}
+
+			if known[entry.Title] {
+				slog.DebugContext(ctx, "Skipping known article", "title", entry.Title)
+				continue
+			}
+			known[entry.Title] = true
+
+			err = q.AddArticleToQueue(ctx, store.AddArticleToQueueParams{Title: entry.Title})
+			if err != nil {
+				return fmt.Errorf("failed to queue %q: %w", entry.Title, err)
+			}
+
+			count++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	slog.InfoContext(ctx, "Queued articles", "queued", count, "skipped", total-count)
+
+	return count, nil
+}
@@ -0,0 +1,38 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestCategoryTitle(t *testing.T) {
+	tests := []struct {
+		category string
+		want     string
+	}{
+		{category: "Planetas", want: "Categoría:Planetas"},
+		{category: "Categoría:Planetas", want: "Categoría:Planetas"},
+		{category: "categoría: Planetas", want: "Categoría:Planetas"},
+		{category: "Category:Planetas", want: "Categoría:Planetas"},
+		{category: " CATEGORY:Planetas del sistema solar ", want: "Categoría:Planetas del sistema solar"},
+	}
+
+	for _, tt := range tests {
+		if got := categoryTitle(tt.category); got != tt.want {
+			t.Errorf("categoryTitle(%q) = %q, want %q", tt.category, got, tt.want)
+		}
+	}
+}
+
+func TestCategoryArticlesStatus(t *testing.T) {
+	newTestParser(t, fakeWikipedia{apiStatus: http.StatusTooManyRequests})
+
+	_, err := CategoryArticles(context.Background(), "Planetas", 0)
+
+	var status statusError
+	if !errors.As(err, &status) || status != http.StatusTooManyRequests {
+		t.Fatalf("got %v, want the status of the response", err)
+	}
+}
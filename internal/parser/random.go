@@ -4,9 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/gbandres98/wikidle2/internal/store"
 )
 
 type wikipediaRandomArticleResponse struct {
@@ -17,13 +20,23 @@ type wikipediaRandomArticleResponse struct {
 	} `json:"query"`
 }
 
-func GetRandomArticleTitle() (string, error) {
-	res, err := http.Get("https://es.wikipedia.org/w/api.php?format=json&formatversion=2&origin=*&action=query&generator=random&grnnamespace=0&grnminsize=50000")
+func GetRandomArticleTitle(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://es.wikipedia.org/w/api.php?format=json&formatversion=2&origin=*&action=query&generator=random&grnnamespace=0&grnminsize=50000", nil)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := wikipediaClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to call random article api: %w", err)
 	}
 	defer res.Body.Close()
 
+	err = checkStatus(res)
+	if err != nil {
+		return "", fmt.Errorf("failed to call random article api: %w", err)
+	}
+
 	var response wikipediaRandomArticleResponse
 	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
 		return "", fmt.Errorf("failed to decode random article response: %w", err)
@@ -36,27 +49,33 @@ func GetRandomArticleTitle() (string, error) {
 	return response.Query.Pages[0].Title, nil
 }
 
-func (p *Parser) GetArticleTitleFromQueue(ctx context.Context) (string, error) {
-	id := GetGameID(time.Now())
-
-	article, err := p.db.GetQueueArticleByDate(ctx, sql.NullString{String: id, Valid: true})
-
-	if err == sql.ErrNoRows {
-		article, err = p.db.GetQueueArticle(ctx)
+// GetArticleFromQueue pops the article queued for today, or else the newest
+// undated one. A random article is used when the queue is empty, in which case
+// queued is false.
+func (p *Parser) GetArticleFromQueue(ctx context.Context) (entry QueueEntry, queued bool, err error) {
+	article, err := p.db.PopQueueArticle(ctx, GetGameID(time.Now()))
+	if errors.Is(err, sql.ErrNoRows) {
+		title, err := GetRandomArticleTitle(ctx)
+		return QueueEntry{Title: title}, false, err
 	}
 
-	if err != nil && err != sql.ErrNoRows {
-		return "", fmt.Errorf("failed to get article from queue: %w", err)
+	if err != nil {
+		return QueueEntry{}, false, fmt.Errorf("failed to pop article from queue: %w", err)
 	}
 
-	if err == nil {
-		err = p.db.DeleteQueueArticle(ctx, article.ID)
-		if err != nil {
-			return "", fmt.Errorf("failed to delete article from queue: %w", err)
-		}
+	return QueueEntry{Title: article.Title, OnDate: article.Ondate.String}, true, nil
+}
 
-		return article.Title, nil
+// requeueArticle puts a popped article back in the queue, where it is the next
+// one to be popped for its day
+func (p *Parser) requeueArticle(ctx context.Context, entry QueueEntry) error {
+	err := p.db.AddArticleToQueue(ctx, store.AddArticleToQueueParams{
+		Title:  entry.Title,
+		Ondate: sql.NullString{String: entry.OnDate, Valid: entry.OnDate != ""},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to queue %q again: %w", entry.Title, err)
 	}
 
-	return GetRandomArticleTitle()
+	return nil
 }
@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// wikipediaLimiter spaces out the requests to Wikipedia, it doesn't limit
+// them until a rate is set
+var wikipediaLimiter = &rateLimiter{}
+
+// SetRateLimit limits the requests to Wikipedia to the given number per
+// second, 0 removing the limit. It returns the previous limit, so it can be
+// restored once the requests that needed the limit are done.
+func SetRateLimit(perSecond float64) (previous float64) {
+	wikipediaLimiter.mu.Lock()
+	defer wikipediaLimiter.mu.Unlock()
+
+	previous = wikipediaLimiter.perSecond
+
+	wikipediaLimiter.perSecond = max(perSecond, 0)
+	wikipediaLimiter.interval = 0
+	if perSecond > 0 {
+		wikipediaLimiter.interval = time.Duration(float64(time.Second) / perSecond)
+	}
+
+	return previous
+}
+
+type rateLimiter struct {
+	mu        sync.Mutex
+	perSecond float64
+	interval  time.Duration
+	// Earliest time the next request can be made
+	next time.Time
+}
+
+// wait blocks until a request can be made or the context is done
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	if l.interval == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	if at.Equal(now) {
+		return nil
+	}
+
+	timer := time.NewTimer(at.Sub(now))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
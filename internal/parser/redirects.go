@@ -0,0 +1,71 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+type redirectsResponse struct {
+	Continue struct {
+		Code string `json:"rdcontinue"`
+	} `json:"continue"`
+	Query struct {
+		Pages []struct {
+			Redirects []struct {
+				Title string `json:"title"`
+			} `json:"redirects"`
+		} `json:"pages"`
+	} `json:"query"`
+}
+
+// parseAliases returns the titles of the articles that redirect to the given
+// one, which are accepted as full title guesses
+func (p *Parser) parseAliases(ctx context.Context, articleTitle string) ([]string, error) {
+	aliases := []string{}
+	cont := ""
+
+	for {
+		query := fmt.Sprintf("https://es.wikipedia.org/w/api.php?format=json&formatversion=2&origin=*&action=query&prop=redirects&rdnamespace=0&rdlimit=max&titles=%s", url.QueryEscape(articleTitle))
+		if cont != "" {
+			query += "&rdcontinue=" + url.QueryEscape(cont)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := wikipediaClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error getting redirects: %w", err)
+		}
+
+		err = checkStatus(res)
+		if err != nil {
+			res.Body.Close()
+			return nil, fmt.Errorf("error getting redirects: %w", err)
+		}
+
+		var response redirectsResponse
+		err = json.NewDecoder(res.Body).Decode(&response)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error decoding redirects: %w", err)
+		}
+
+		for _, page := range response.Query.Pages {
+			for _, redirect := range page.Redirects {
+				aliases = append(aliases, redirect.Title)
+			}
+		}
+
+		if response.Continue.Code == "" {
+			return aliases, nil
+		}
+
+		cont = response.Continue.Code
+	}
+}
@@ -1,45 +1,41 @@
 package parser
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
-	"net/http"
 	"net/url"
+	"slices"
 	"strings"
+
+	"github.com/PuerkitoBio/goquery"
 )
 
-type relatedResponse struct {
-	Query struct {
-		Pages []struct {
-			Categories []struct {
-				Title string `json:"title"`
-			} `json:"categories"`
-		} `json:"pages"`
-	} `json:"query"`
-}
+// parseRelated returns the names of the categories of a page, read from its
+// HTML so they belong to the same revision as the article body. They are
+// sorted by name, as the API lists them.
+func parseRelated(doc *goquery.Document) []string {
+	seen := map[string]bool{}
+	var related []string
 
-func (p *Parser) parseRelated(articleTitle string) ([]string, error) {
-	url := fmt.Sprintf("https://es.wikipedia.org/w/api.php?format=json&formatversion=2&origin=*&action=query&prop=categories&titles=%s", url.PathEscape(articleTitle))
+	// <link rel="mw:PageProp/Category" href="./Categoría:Planetas_del_sistema_solar#Mercurio">
+	doc.Find(`link[rel~="mw:PageProp/Category"]`).Each(func(i int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		href, _, _ = strings.Cut(strings.TrimPrefix(href, "./"), "#")
 
-	res, err := http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("error getting related articles: %w", err)
-	}
+		title, err := url.PathUnescape(href)
+		if err != nil {
+			title = href
+		}
 
-	var related relatedResponse
-	if err := json.NewDecoder(res.Body).Decode(&related); err != nil {
-		log.Println(url)
-		return nil, fmt.Errorf("error decoding related articles: %w", err)
-	}
+		_, name, ok := strings.Cut(strings.ReplaceAll(title, "_", " "), ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || seen[name] {
+			return
+		}
 
-	var relatedTitles []string
-	for _, category := range related.Query.Pages[0].Categories {
-		titleSplit := strings.Split(category.Title, ":")
-		title := titleSplit[len(titleSplit)-1]
+		seen[name] = true
+		related = append(related, name)
+	})
 
-		relatedTitles = append(relatedTitles, title)
-	}
+	slices.Sort(related)
 
-	return relatedTitles, nil
+	return related
 }
@@ -0,0 +1,29 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestParseRelated(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body>
+<section><p>Mercurio es el planeta más cercano al Sol.</p></section>
+<link rel="mw:PageProp/Category" href="./Categoría:Planetas_del_sistema_solar#Mercurio">
+<link rel="mw:PageProp/Category" href="./Categor%C3%ADa:Mercurio_(planeta)">
+<link rel="mw:PageProp/Category" href="./Categoría:Planetas_del_sistema_solar">
+<link rel="mw:PageProp/Redirect" href="./Mercurio">
+</body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	related := parseRelated(doc)
+
+	want := []string{"Mercurio (planeta)", "Planetas del sistema solar"}
+	if !slices.Equal(related, want) {
+		t.Errorf("got %q, want %q", related, want)
+	}
+}
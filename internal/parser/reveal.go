@@ -0,0 +1,49 @@
+package parser
+
+import "unicode/utf8"
+
+// RevealPolicy defines which classes of tokens are shown from the start and
+// never have to be guessed
+type RevealPolicy struct {
+	Numbers       bool     `json:"numbers"`
+	SingleLetters bool     `json:"singleLetters"`
+	Stopwords     bool     `json:"stopwords"`
+	Words         []string `json:"words,omitempty"`
+}
+
+func DefaultRevealPolicy() RevealPolicy {
+	return RevealPolicy{
+		Numbers:   true,
+		Stopwords: true,
+	}
+}
+
+// legacyRevealPolicy is the policy articles parsed before reveal policies
+// existed were obscured with
+var legacyRevealPolicy = RevealPolicy{
+	Stopwords: true,
+}
+
+func (r RevealPolicy) IsRevealed(word string) bool {
+	word = Normalize(word)
+
+	if r.Numbers && isNumeric(word) {
+		return true
+	}
+
+	if r.SingleLetters && utf8.RuneCountInString(word) == 1 {
+		return true
+	}
+
+	if r.Stopwords && IsExcludedWord(word) {
+		return true
+	}
+
+	for _, revealed := range r.Words {
+		if Normalize(revealed) == word {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type revisionsResponse struct {
+	Query struct {
+		BadRevisionIDs map[string]any `json:"badrevids"`
+		Pages          []struct {
+			Title     string `json:"title"`
+			Missing   bool   `json:"missing"`
+			Revisions []struct {
+				ID int64 `json:"revid"`
+			} `json:"revisions"`
+		} `json:"pages"`
+	} `json:"query"`
+}
+
+// latestRevision returns the id of the current revision of an article
+func latestRevision(ctx context.Context, title string) (int64, error) {
+	_, revision, err := queryRevision(ctx, "titles", title)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the revision of %q: %w", title, err)
+	}
+
+	return revision, nil
+}
+
+// revisionTitle returns the title of the article a revision belongs to
+func revisionTitle(ctx context.Context, revision int64) (string, error) {
+	title, _, err := queryRevision(ctx, "revids", strconv.FormatInt(revision, 10))
+	if err != nil {
+		return "", fmt.Errorf("failed to get revision %d: %w", revision, err)
+	}
+
+	return title, nil
+}
+
+func queryRevision(ctx context.Context, param string, value string) (string, int64, error) {
+	query := url.Values{
+		"format":        {"json"},
+		"formatversion": {"2"},
+		"origin":        {"*"},
+		"action":        {"query"},
+		"prop":          {"revisions"},
+		"rvprop":        {"ids"},
+		param:           {value},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://es.wikipedia.org/w/api.php?"+query.Encode(), nil)
+	if err != nil {
+		return "", 0, err
+	}
+
+	res, err := wikipediaClient.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer res.Body.Close()
+
+	err = checkStatus(res)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var response revisionsResponse
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if len(response.Query.BadRevisionIDs) > 0 {
+		return "", 0, fmt.Errorf("%w: the revision doesn't exist", ErrArticleRejected)
+	}
+
+	if len(response.Query.Pages) == 0 || response.Query.Pages[0].Missing || len(response.Query.Pages[0].Revisions) == 0 {
+		return "", 0, fmt.Errorf("%w: the page doesn't exist", ErrArticleRejected)
+	}
+
+	page := response.Query.Pages[0]
+	return page.Title, page.Revisions[0].ID, nil
+}
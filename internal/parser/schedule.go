@@ -0,0 +1,310 @@
+package parser
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gbandres98/wikidle2/internal/store"
+)
+
+// ErrScheduleConflict is returned when an article can't be pinned to a day
+var ErrScheduleConflict = errors.New("schedule conflict")
+
+// CalendarDay is a day with its article, if it has already been parsed, and
+// the articles pinned to it
+type CalendarDay struct {
+	GameID    string   `json:"game_id"`
+	Article   string   `json:"article,omitempty"`
+	Pinned    []string `json:"pinned,omitempty"`
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// schedule holds the pinned articles and the played titles
+type schedule struct {
+	// Game id -> pinned titles
+	pins map[string][]string
+	// Title -> game ids it's pinned to
+	days   map[string][]string
+	played map[string]bool
+}
+
+func loadSchedule(ctx context.Context, db *store.Queries) (schedule, error) {
+	pinned, err := db.ListScheduledArticles(ctx)
+	if err != nil {
+		return schedule{}, fmt.Errorf("failed to list scheduled articles: %w", err)
+	}
+
+	played, err := db.GetArticleTitles(ctx)
+	if err != nil {
+		return schedule{}, fmt.Errorf("failed to get played articles: %w", err)
+	}
+
+	s := schedule{
+		pins:   map[string][]string{},
+		days:   map[string][]string{},
+		played: map[string]bool{},
+	}
+
+	for _, pin := range pinned {
+		s.pins[pin.Ondate.String] = append(s.pins[pin.Ondate.String], pin.Title)
+		s.days[pin.Title] = append(s.days[pin.Title], pin.Ondate.String)
+	}
+
+	for _, title := range played {
+		s.played[title] = true
+	}
+
+	return s, nil
+}
+
+// day builds the calendar day of a game id, listing what stops its pinned
+// articles from being used
+func (s schedule) day(gameID string, article string) CalendarDay {
+	day := CalendarDay{
+		GameID:  gameID,
+		Article: article,
+		Pinned:  s.pins[gameID],
+	}
+
+	if len(day.Pinned) == 0 {
+		return day
+	}
+
+	if len(day.Pinned) > 1 {
+		day.Conflicts = append(day.Conflicts, fmt.Sprintf("%d articles are pinned to the same day", len(day.Pinned)))
+	}
+
+	if article != "" {
+		day.Conflicts = append(day.Conflicts, "the day already has an article")
+	} else if gameID < GetGameID(time.Now()) {
+		day.Conflicts = append(day.Conflicts, "the day has passed")
+	}
+
+	for _, title := range day.Pinned {
+		if s.played[title] {
+			day.Conflicts = append(day.Conflicts, fmt.Sprintf("%q has already been played", title))
+		}
+
+		if len(s.days[title]) > 1 {
+			day.Conflicts = append(day.Conflicts, fmt.Sprintf("%q is pinned to %d days", title, len(s.days[title])))
+		}
+	}
+
+	return day
+}
+
+// Calendar returns the given number of days starting from a date
+func Calendar(ctx context.Context, db *store.Queries, from time.Time, days int) ([]CalendarDay, error) {
+	s, err := loadSchedule(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	articles, err := articleTitlesBetween(ctx, db, GetGameID(from), GetGameID(from.AddDate(0, 0, days-1)))
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := make([]CalendarDay, 0, days)
+	for i := 0; i < days; i++ {
+		gameID := GetGameID(from.AddDate(0, 0, i))
+		calendar = append(calendar, s.day(gameID, articles[gameID]))
+	}
+
+	return calendar, nil
+}
+
+// ScheduleConflicts returns the days with pinned articles that won't be used
+// as they are
+func ScheduleConflicts(ctx context.Context, db *store.Queries) ([]CalendarDay, error) {
+	s, err := loadSchedule(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	gameIDs := make([]string, 0, len(s.pins))
+	for gameID := range s.pins {
+		gameIDs = append(gameIDs, gameID)
+	}
+	slices.Sort(gameIDs)
+
+	if len(gameIDs) == 0 {
+		return nil, nil
+	}
+
+	articles, err := articleTitlesBetween(ctx, db, gameIDs[0], gameIDs[len(gameIDs)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := []CalendarDay{}
+	for _, gameID := range gameIDs {
+		if day := s.day(gameID, articles[gameID]); len(day.Conflicts) > 0 {
+			conflicts = append(conflicts, day)
+		}
+	}
+
+	return conflicts, nil
+}
+
+func articleTitlesBetween(ctx context.Context, db *store.Queries, from string, to string) (map[string]string, error) {
+	rows, err := db.ListArticleTitlesBetween(ctx, store.ListArticleTitlesBetweenParams{FromID: from, ToID: to})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list articles: %w", err)
+	}
+
+	titles := make(map[string]string, len(rows))
+	for _, row := range rows {
+		titles[row.ID] = row.Title
+	}
+
+	return titles, nil
+}
+
+// ScheduleArticle pins an article to a day, moving it out of the undated queue
+// if it was there. Unless replace is set, it fails with ErrScheduleConflict if
+// another article is pinned to the day. The queries should be bound to a
+// transaction, so the schedule doesn't change between the checks and the pin.
+func ScheduleArticle(ctx context.Context, db *store.Queries, title string, date time.Time, replace bool) error {
+	gameID := GetGameID(date)
+
+	if gameID < GetGameID(time.Now()) {
+		return fmt.Errorf("%w: %s has passed", ErrScheduleConflict, gameID)
+	}
+
+	s, err := loadSchedule(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	articles, err := articleTitlesBetween(ctx, db, gameID, gameID)
+	if err != nil {
+		return err
+	}
+
+	if article, ok := articles[gameID]; ok {
+		return fmt.Errorf("%w: %s already has the article %q", ErrScheduleConflict, gameID, article)
+	}
+
+	if s.played[title] {
+		return fmt.Errorf("%w: %q has already been played", ErrScheduleConflict, title)
+	}
+
+	for _, day := range s.days[title] {
+		if day != gameID {
+			return fmt.Errorf("%w: %q is already pinned to %s", ErrScheduleConflict, title, day)
+		}
+	}
+
+	pinned := s.pins[gameID]
+	if len(pinned) == 1 && pinned[0] == title {
+		return nil
+	}
+
+	if len(pinned) > 0 {
+		if !replace {
+			return fmt.Errorf("%w: %s already has %s pinned", ErrScheduleConflict, gameID, strings.Join(pinned, ", "))
+		}
+
+		_, err = db.DeleteScheduledArticles(ctx, sql.NullString{String: gameID, Valid: true})
+		if err != nil {
+			return fmt.Errorf("failed to unschedule %s: %w", gameID, err)
+		}
+	}
+
+	err = db.DeleteUndatedQueueArticle(ctx, title)
+	if err != nil {
+		return fmt.Errorf("failed to remove %q from the queue: %w", title, err)
+	}
+
+	err = db.AddArticleToQueue(ctx, store.AddArticleToQueueParams{
+		Title:  title,
+		Ondate: sql.NullString{String: gameID, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to schedule %q: %w", title, err)
+	}
+
+	return nil
+}
+
+// UnscheduleDay removes the articles pinned to a day and returns how many
+// there were
+func UnscheduleDay(ctx context.Context, db *store.Queries, date time.Time) (int64, error) {
+	gameID := GetGameID(date)
+
+	count, err := db.DeleteScheduledArticles(ctx, sql.NullString{String: gameID, Valid: true})
+	if err != nil {
+		return 0, fmt.Errorf("failed to unschedule %s: %w", gameID, err)
+	}
+
+	return count, nil
+}
+
+// ScheduleTheme pins random articles among the titles to consecutive days,
+// starting from a date, under their canonical titles. Invalid titles and the
+// ones that have been played or are pinned to other days are skipped. Either
+// every day is scheduled or none is.
+func ScheduleTheme(ctx context.Context, db *store.DB, titles []string, start time.Time, days int) ([]QueueEntry, error) {
+	canonical, rejected, err := ResolveTitles(ctx, titles)
+	if err != nil {
+		return nil, err
+	}
+
+	for title, err := range rejected {
+		slog.WarnContext(ctx, "Skipping invalid article", "title", title, "error", err)
+	}
+
+	s, err := loadSchedule(ctx, db.Queries)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []string{}
+	seen := make(map[string]bool, len(titles))
+	for _, title := range titles {
+		title, ok := canonical[title]
+		if !ok || seen[title] {
+			continue
+		}
+		seen[title] = true
+
+		if !s.played[title] && len(s.days[title]) == 0 {
+			candidates = append(candidates, title)
+		}
+	}
+
+	if len(candidates) < days {
+		return nil, fmt.Errorf("only %d of the %d articles can be scheduled, %d are needed", len(candidates), len(titles), days)
+	}
+
+	rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+
+	entries := make([]QueueEntry, days)
+	for i := range entries {
+		entries[i] = QueueEntry{Title: candidates[i], OnDate: GetGameID(start.AddDate(0, 0, i))}
+	}
+
+	err = db.InTx(ctx, func(q *store.Queries) error {
+		for i, entry := range entries {
+			err := ScheduleArticle(ctx, q, entry.Title, start.AddDate(0, 0, i), false)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,247 @@
+package parser
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gbandres98/wikidle2/internal/store"
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	runSuccess = "success"
+	runFailed  = "failed"
+)
+
+// Name of the lease held by the instance running the daily job
+const dailyLease = "daily-parse"
+
+type SchedulerConfig struct {
+	Cron string
+	// Attempts of the daily job before giving up and alerting
+	Attempts int
+	// Delay before the first retry, doubled on every retry up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Duration of the lease that stops other instances from running the daily
+	// job at the same time, renewed while the job runs
+	LeaseDuration time.Duration
+}
+
+func DefaultSchedulerConfig() SchedulerConfig {
+	return SchedulerConfig{
+		Cron:          "0 0 * * *",
+		Attempts:      5,
+		Backoff:       time.Minute,
+		MaxBackoff:    30 * time.Minute,
+		LeaseDuration: 5 * time.Minute,
+	}
+}
+
+// Scheduler parses the article of the day on a cron schedule
+type Scheduler struct {
+	parser  *Parser
+	config  SchedulerConfig
+	alerter Alerter
+	cron    *cron.Cron
+	lease   *lease
+}
+
+func NewScheduler(parser *Parser, config SchedulerConfig, alerter Alerter) *Scheduler {
+	if config.LeaseDuration <= 0 {
+		config.LeaseDuration = DefaultSchedulerConfig().LeaseDuration
+	}
+
+	return &Scheduler{
+		parser:  parser,
+		config:  config,
+		alerter: alerter,
+		cron:    cron.New(),
+		lease: &lease{
+			db:       parser.db.Queries,
+			name:     dailyLease,
+			holder:   uuid.NewString(),
+			duration: config.LeaseDuration,
+		},
+	}
+}
+
+// Start runs the daily job on the schedule. Jobs don't stop when the context
+// is cancelled, so they can finish while shutting down.
+func (s *Scheduler) Start(ctx context.Context) error {
+	_, err := s.cron.AddFunc(s.config.Cron, func() {
+		ctx := context.WithoutCancel(ctx)
+
+		slog.InfoContext(ctx, "Running article parsing job")
+
+		err := s.RunDaily(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Article parsing job failed", "error", err)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to schedule article parsing job: %w", err)
+	}
+
+	s.cron.Start()
+	slog.InfoContext(ctx, "Started cron schedule", "cron", s.config.Cron)
+
+	return nil
+}
+
+// Stop stops the schedule, the returned context is done once the running
+// jobs finish
+func (s *Scheduler) Stop() context.Context {
+	return s.cron.Stop()
+}
+
+// RunDaily parses the article of the day unless it already exists or another
+// instance is already running the job. Failures are retried with exponential
+// backoff, each run is recorded and the alerter is called when every attempt
+// fails.
+func (s *Scheduler) RunDaily(ctx context.Context) error {
+	acquired, err := s.lease.acquire(ctx)
+	if err != nil {
+		s.alert(ctx, err.Error())
+		return err
+	}
+
+	if !acquired {
+		slog.InfoContext(ctx, "Daily job is running on another instance, skipping")
+		return nil
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	kept := make(chan struct{})
+	go func() {
+		s.lease.keep(jobCtx, cancel)
+		close(kept)
+	}()
+
+	err = s.runDaily(jobCtx)
+
+	// Stop renewing before releasing, so the lease isn't taken back
+	cancel()
+	<-kept
+
+	if err := s.lease.release(context.WithoutCancel(ctx)); err != nil {
+		slog.WarnContext(ctx, "failed to release lease", "error", err)
+	}
+
+	return err
+}
+
+func (s *Scheduler) runDaily(ctx context.Context) error {
+	gameID := GetGameID(time.Now())
+
+	var exists bool
+	_, err := s.retry(ctx, func() error {
+		_, err := s.parser.db.GetArticleIndexByID(ctx, gameID)
+		exists = err == nil
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+
+		return err
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to check the article of %s: %w", gameID, err)
+		s.alert(ctx, err.Error())
+		return err
+	}
+
+	if exists {
+		slog.InfoContext(ctx, "Article already exists in db", "game_id", gameID)
+		return nil
+	}
+
+	slog.InfoContext(ctx, "No article in db, parsing article from queue", "game_id", gameID)
+
+	runID := uuid.NewString()
+	err = s.parser.db.CreateParseRun(ctx, store.CreateParseRunParams{
+		ID:        runID,
+		GameID:    gameID,
+		StartedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "failed to record parse run", "error", err)
+	}
+
+	var title string
+	attempts, err := s.retry(ctx, func() error {
+		var err error
+		title, err = s.parser.ParseNextArticle(ctx)
+		return err
+	})
+
+	s.finishRun(ctx, runID, attempts, title, err)
+
+	if err != nil {
+		err = fmt.Errorf("failed to parse the article of %s after %d attempts: %w", gameID, attempts, err)
+		s.alert(ctx, err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// retry calls fn until it succeeds or runs out of attempts, and returns the
+// number of attempts made
+func (s *Scheduler) retry(ctx context.Context, fn func() error) (int, error) {
+	backoff := s.config.Backoff
+	attempt := 1
+
+	for ; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return attempt, nil
+		}
+
+		if attempt >= s.config.Attempts {
+			return attempt, err
+		}
+
+		slog.WarnContext(ctx, "Attempt failed, retrying", "attempt", attempt, "backoff", backoff, "error", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return attempt, errors.Join(err, ctx.Err())
+		}
+
+		backoff = min(backoff*2, s.config.MaxBackoff)
+	}
+}
+
+func (s *Scheduler) finishRun(ctx context.Context, runID string, attempts int, title string, runErr error) {
+	params := store.FinishParseRunParams{
+		FinishedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		Attempts:   int32(attempts),
+		Status:     runSuccess,
+		Title:      sql.NullString{String: title, Valid: title != ""},
+		ID:         runID,
+	}
+
+	if runErr != nil {
+		params.Status = runFailed
+		params.Error = sql.NullString{String: runErr.Error(), Valid: true}
+	}
+
+	// The run is recorded even when it was stopped by a shutdown
+	err := s.parser.db.FinishParseRun(context.WithoutCancel(ctx), params)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to record parse run", "run_id", runID, "error", err)
+	}
+}
+
+func (s *Scheduler) alert(ctx context.Context, message string) {
+	err := s.alerter.Alert(ctx, message)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to send alert", "message", message, "error", err)
+	}
+}
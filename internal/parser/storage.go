@@ -0,0 +1,394 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gbandres98/wikidle2/internal/health"
+	"github.com/gbandres98/wikidle2/internal/store"
+)
+
+const (
+	// The whole article is stored as JSON in the content column
+	legacyFormat = 0
+	// The content column only holds metadata, the token index and the shared
+	// HTML are stored compressed in their own columns
+	compactFormat = 1
+)
+
+// tokenIndex is the part of the article needed to check guesses
+type tokenIndex struct {
+	Tokens map[string][]int
+	Words  map[int]string
+}
+
+// legacyArticle is the layout of articles stored in the legacy format
+type legacyArticle struct {
+	Article
+	Tokens         map[string][]int
+	Words          map[int]string
+	HTML           template.HTML
+	UnobscuredHTML template.HTML
+}
+
+// LoadArticle loads an article with its token index and HTML
+func LoadArticle(ctx context.Context, db *store.Queries, id string) (Article, error) {
+	row, err := db.GetArticleByID(ctx, id)
+	if err != nil {
+		return Article{}, err
+	}
+
+	if row.Format == legacyFormat {
+		return decodeLegacyArticle(row.Content)
+	}
+
+	article, err := decodeArticleIndex(row.Content, row.TokenIndex)
+	if err != nil {
+		return Article{}, err
+	}
+
+	html, err := gunzip(row.Html)
+	if err != nil {
+		return Article{}, fmt.Errorf("failed to decompress article html: %w", err)
+	}
+
+	article.UnobscuredHTML = template.HTML(html)
+
+	article.HTML, err = obscureHTML(article)
+	if err != nil {
+		return Article{}, err
+	}
+
+	return article, nil
+}
+
+// ArticleOfTheDayCheck is a readiness check that fails unless today's article
+// is stored and can be loaded. Only the token index is decoded, the HTML is
+// just checked to decompress.
+func ArticleOfTheDayCheck(db *store.Queries) health.Check {
+	return health.Check{
+		Name: "article",
+		Run: func(ctx context.Context) error {
+			id := GetGameID(time.Now())
+
+			article, err := LoadArticleIndex(ctx, db, id)
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("no article for game %s", id)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to load article for game %s: %w", id, err)
+			}
+
+			if len(article.TitleTokens) == 0 || len(article.Words) == 0 {
+				return fmt.Errorf("article for game %s is empty", id)
+			}
+
+			err = checkArticleHTML(ctx, db, id)
+			if err != nil {
+				return fmt.Errorf("failed to check html of article for game %s: %w", id, err)
+			}
+
+			return nil
+		},
+	}
+}
+
+// checkArticleHTML makes sure the stored HTML of an article decompresses and
+// is not empty, without parsing it
+func checkArticleHTML(ctx context.Context, db *store.Queries, id string) error {
+	row, err := db.GetArticleHTMLByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Legacy articles keep their HTML in the content column, which has been
+	// decoded along the token index
+	if row.Format == legacyFormat {
+		return nil
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(row.Html))
+	if err != nil {
+		return fmt.Errorf("failed to decompress article html: %w", err)
+	}
+	defer gz.Close()
+
+	n, err := io.Copy(io.Discard, gz)
+	if err != nil {
+		return fmt.Errorf("failed to decompress article html: %w", err)
+	}
+
+	if n == 0 {
+		return errors.New("article html is empty")
+	}
+
+	return nil
+}
+
+// LoadArticleIndex loads an article with its token index, without reading its
+// HTML
+func LoadArticleIndex(ctx context.Context, db *store.Queries, id string) (Article, error) {
+	row, err := db.GetArticleIndexByID(ctx, id)
+	if err != nil {
+		return Article{}, err
+	}
+
+	if row.Format == legacyFormat {
+		article, err := decodeLegacyArticle(row.Content)
+		article.HTML, article.UnobscuredHTML = "", ""
+		return article, err
+	}
+
+	return decodeArticleIndex(row.Content, row.TokenIndex)
+}
+
+// MigrateArticleStorage rewrites the articles stored in the legacy format
+func MigrateArticleStorage(ctx context.Context, db *store.Queries) error {
+	ids, err := db.GetLegacyArticleIDs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get legacy articles: %w", err)
+	}
+
+	for _, id := range ids {
+		row, err := db.GetArticleByID(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to get article %s: %w", id, err)
+		}
+
+		article, err := migrateLegacyArticle(row.Content)
+		if err != nil {
+			slog.WarnContext(ctx, "Skipping migration of article", "game_id", id, "error", err)
+			continue
+		}
+
+		params, err := encodeArticle(article)
+		if err != nil {
+			return fmt.Errorf("failed to encode article %s: %w", id, err)
+		}
+
+		err = db.SaveArticle(ctx, params)
+		if err != nil {
+			return fmt.Errorf("failed to save article %s: %w", id, err)
+		}
+
+		slog.InfoContext(ctx, "Migrated article to the compact format", "game_id", id,
+			"old_bytes", len(row.Content), "new_bytes", len(params.Content)+len(params.TokenIndex)+len(params.Html))
+	}
+
+	return nil
+}
+
+func encodeArticle(article Article) (store.SaveArticleParams, error) {
+	content, err := json.Marshal(article)
+	if err != nil {
+		return store.SaveArticleParams{}, err
+	}
+
+	index, err := json.Marshal(tokenIndex{Tokens: article.Tokens, Words: article.Words})
+	if err != nil {
+		return store.SaveArticleParams{}, err
+	}
+
+	compressedIndex, err := gzipBytes(index)
+	if err != nil {
+		return store.SaveArticleParams{}, fmt.Errorf("failed to compress token index: %w", err)
+	}
+
+	compressedHTML, err := gzipBytes([]byte(article.UnobscuredHTML))
+	if err != nil {
+		return store.SaveArticleParams{}, fmt.Errorf("failed to compress article html: %w", err)
+	}
+
+	return store.SaveArticleParams{
+		ID:         article.ID,
+		Content:    content,
+		Title:      article.Title,
+		Format:     compactFormat,
+		TokenIndex: compressedIndex,
+		Html:       compressedHTML,
+	}, nil
+}
+
+func decodeArticleIndex(content []byte, compressedIndex []byte) (Article, error) {
+	var article Article
+	err := json.Unmarshal(content, &article)
+	if err != nil {
+		return Article{}, err
+	}
+
+	data, err := gunzip(compressedIndex)
+	if err != nil {
+		return Article{}, fmt.Errorf("failed to decompress token index: %w", err)
+	}
+
+	var index tokenIndex
+	err = json.Unmarshal(data, &index)
+	if err != nil {
+		return Article{}, err
+	}
+
+	article.Tokens = index.Tokens
+	article.Words = index.Words
+
+	return article, nil
+}
+
+func decodeLegacyArticle(content []byte) (Article, error) {
+	var legacy legacyArticle
+	err := json.Unmarshal(content, &legacy)
+	if err != nil {
+		return Article{}, err
+	}
+
+	article := legacy.Article
+	article.Tokens = legacy.Tokens
+	article.Words = legacy.Words
+	article.HTML = legacy.HTML
+	article.UnobscuredHTML = legacy.UnobscuredHTML
+
+	return article, nil
+}
+
+// migrateLegacyArticle builds the shared HTML of a legacy article by giving
+// the obscured spans of its unobscured HTML the ids they have in the obscured
+// one
+func migrateLegacyArticle(content []byte) (Article, error) {
+	article, err := decodeLegacyArticle(content)
+	if err != nil {
+		return Article{}, err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(article.UnobscuredHTML)))
+	if err != nil {
+		return Article{}, err
+	}
+
+	var mismatch error
+	doc.Find("span.obscured").Each(func(i int, s *goquery.Selection) {
+		word, ok := article.Words[i]
+		if !ok || mismatch != nil {
+			return
+		}
+
+		if s.Text() != word {
+			mismatch = fmt.Errorf("span %d is %q in the unobscured html but %q in the token index", i, s.Text(), word)
+			return
+		}
+
+		s.SetAttr("id", fmt.Sprintf("obscured-%d", i))
+	})
+	if mismatch != nil {
+		return Article{}, mismatch
+	}
+
+	// Articles parsed before images were stored locally keep the image
+	// sources of their obscured HTML
+	if article.Images == nil {
+		obscured, err := goquery.NewDocumentFromReader(strings.NewReader(string(article.HTML)))
+		if err != nil {
+			return Article{}, err
+		}
+
+		article.Images = []ArticleImage{}
+		obscured.Find("img").Each(func(i int, s *goquery.Selection) {
+			image := ArticleImage{}
+			if src, ok := s.Attr("src"); ok {
+				image.Levels = []string{src}
+			}
+
+			article.Images = append(article.Images, image)
+		})
+	}
+
+	html, err := doc.Html()
+	if err != nil {
+		return Article{}, err
+	}
+
+	article.UnobscuredHTML = template.HTML(html)
+
+	return article, nil
+}
+
+// obscureHTML renders the obscured HTML of an article from its shared HTML
+func obscureHTML(article Article) (template.HTML, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(article.UnobscuredHTML)))
+	if err != nil {
+		return "", err
+	}
+
+	obscureDocument(doc, article)
+
+	html, err := doc.Html()
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(html), nil
+}
+
+// obscureDocument hides the words of the indexed spans, disables the links and
+// points the images to their most obscured copies
+func obscureDocument(doc *goquery.Document, article Article) {
+	doc.Find("a").Each(func(i int, s *goquery.Selection) {
+		s.SetAttr("href", "javascript:void(0);")
+		s.RemoveAttr("title")
+	})
+
+	obscureImages(doc, article.Images)
+
+	doc.Find(`span.obscured[id^="obscured-"]`).Each(func(i int, s *goquery.Selection) {
+		id, err := strconv.Atoi(strings.TrimPrefix(s.AttrOr("id", ""), "obscured-"))
+		if err != nil {
+			return
+		}
+
+		if word, ok := article.Words[id]; ok {
+			s.SetText(strings.Repeat("#", utf8.RuneCountInString(word)))
+		}
+	})
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	gz, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = gz.Write(data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = gz.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	return io.ReadAll(gz)
+}
@@ -0,0 +1,178 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidTitle is returned for titles that don't name a playable article
+var ErrInvalidTitle = fmt.Errorf("%w: invalid title", ErrArticleRejected)
+
+// Titles the API resolves in a single request
+const titlesPerRequest = 50
+
+// Redirects followed at most, in case of redirect loops
+const maxRedirects = 5
+
+type titlesResponse struct {
+	Query struct {
+		Normalized []titleMapping `json:"normalized"`
+		Redirects  []titleMapping `json:"redirects"`
+		Pages      []struct {
+			Title     string            `json:"title"`
+			Namespace int               `json:"ns"`
+			Missing   bool              `json:"missing"`
+			Invalid   bool              `json:"invalid"`
+			PageProps map[string]string `json:"pageprops"`
+		} `json:"pages"`
+	} `json:"query"`
+}
+
+type titleMapping struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ResolveTitle returns the canonical title of the article a title names,
+// following redirects. It fails with ErrInvalidTitle if the title doesn't name
+// an article or names a disambiguation page.
+func ResolveTitle(ctx context.Context, title string) (string, error) {
+	resolved, rejected, err := ResolveTitles(ctx, []string{title})
+	if err != nil {
+		return "", err
+	}
+
+	if err, ok := rejected[title]; ok {
+		return "", err
+	}
+
+	return resolved[title], nil
+}
+
+// ResolveTitles resolves titles like ResolveTitle, in batches. Titles that
+// can't be resolved are left out of the canonical titles and returned with
+// their reason.
+func ResolveTitles(ctx context.Context, titles []string) (map[string]string, map[string]error, error) {
+	resolved := make(map[string]string, len(titles))
+	rejected := map[string]error{}
+
+	for start := 0; start < len(titles); start += titlesPerRequest {
+		batch := titles[start:min(start+titlesPerRequest, len(titles))]
+
+		err := resolveTitleBatch(ctx, batch, resolved, rejected)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return resolved, rejected, nil
+}
+
+func resolveTitleBatch(ctx context.Context, titles []string, resolved map[string]string, rejected map[string]error) error {
+	valid := make([]string, 0, len(titles))
+	for _, title := range titles {
+		if strings.TrimSpace(title) == "" || strings.Contains(title, "|") {
+			rejected[title] = fmt.Errorf("%w: %q", ErrInvalidTitle, title)
+		} else {
+			valid = append(valid, title)
+		}
+	}
+
+	if len(valid) == 0 {
+		return nil
+	}
+
+	query := url.Values{
+		"format":        {"json"},
+		"formatversion": {"2"},
+		"origin":        {"*"},
+		"action":        {"query"},
+		"redirects":     {"1"},
+		"prop":          {"pageprops"},
+		"ppprop":        {"disambiguation"},
+		"titles":        {strings.Join(valid, "|")},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://es.wikipedia.org/w/api.php", strings.NewReader(query.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to build titles request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := wikipediaClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to resolve titles: %w", err)
+	}
+	defer res.Body.Close()
+
+	err = checkStatus(res)
+	if err != nil {
+		return fmt.Errorf("failed to resolve titles: %w", err)
+	}
+
+	var response titlesResponse
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return fmt.Errorf("failed to decode titles response: %w", err)
+	}
+
+	normalized := map[string]string{}
+	for _, mapping := range response.Query.Normalized {
+		normalized[mapping.From] = mapping.To
+	}
+
+	redirects := map[string]string{}
+	for _, mapping := range response.Query.Redirects {
+		redirects[mapping.From] = mapping.To
+	}
+
+	// Page title -> why it can't be played, empty if it can
+	pages := map[string]string{}
+	for _, page := range response.Query.Pages {
+		switch {
+		case page.Invalid:
+			pages[page.Title] = "is not a valid title"
+		case page.Missing:
+			pages[page.Title] = "doesn't exist"
+		case page.Namespace != 0:
+			pages[page.Title] = "is not an article"
+		default:
+			if _, ok := page.PageProps["disambiguation"]; ok {
+				pages[page.Title] = "is a disambiguation page"
+			} else {
+				pages[page.Title] = ""
+			}
+		}
+	}
+
+	for _, title := range valid {
+		target := title
+		if to, ok := normalized[target]; ok {
+			target = to
+		}
+
+		for i := 0; i < maxRedirects; i++ {
+			to, ok := redirects[target]
+			if !ok {
+				break
+			}
+			target = to
+		}
+
+		reason, ok := pages[target]
+		switch {
+		case !ok:
+			rejected[title] = fmt.Errorf("%w: %q was not found", ErrInvalidTitle, title)
+		case reason != "":
+			rejected[title] = fmt.Errorf("%w: %q %s", ErrInvalidTitle, target, reason)
+		default:
+			resolved[title] = target
+		}
+	}
+
+	return nil
+}
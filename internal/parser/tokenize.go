@@ -0,0 +1,195 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// wordRegex is the definition of a word shared by the body tokenizer and the
+// title tokenizer: a run of letters, combining marks, digits and connectors
+var wordRegex = regexp.MustCompile(`[\p{L}\p{Mn}\p{Nd}\p{Pc}]+`)
+
+// skipTokenizing are the elements whose text is not article content
+var skipTokenizing = map[atom.Atom]bool{
+	atom.Head:     true,
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Math:     true,
+	atom.Svg:      true,
+}
+
+// disambiguatorRegex matches titles like "Mercurio (planeta)"
+var disambiguatorRegex = regexp.MustCompile(`^(.+?)\s*\(([^()]+)\)$`)
+
+// ErrUnwinnableTitle is returned for articles whose title can't be guessed, it
+// is a rejection like the quality checks
+var ErrUnwinnableTitle = fmt.Errorf("%w: title can't be won", ErrArticleRejected)
+
+type textPart struct {
+	Text   string
+	IsWord bool
+}
+
+// splitWords splits a plain text string into words and the text between them,
+// using the same definition of a word as the article tokenizer
+func splitWords(text string) []textPart {
+	parts := []textPart{}
+	last := 0
+
+	for _, match := range wordRegex.FindAllStringIndex(text, -1) {
+		if match[0] > last {
+			parts = append(parts, textPart{Text: text[last:match[0]]})
+		}
+
+		parts = append(parts, textPart{Text: text[match[0]:match[1]], IsWord: true})
+		last = match[1]
+	}
+
+	if last < len(text) {
+		parts = append(parts, textPart{Text: text[last:]})
+	}
+
+	return parts
+}
+
+// tokenize wraps every word in the text nodes of the document in an obscured
+// span. Text is kept as parsed, so entities and whitespace are rendered back
+// as they were.
+func tokenize(node *html.Node) {
+	if node.Type == html.ElementNode && skipTokenizing[node.DataAtom] {
+		return
+	}
+
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+
+		if child.Type == html.TextNode {
+			tokenizeText(child)
+		} else {
+			tokenize(child)
+		}
+
+		child = next
+	}
+}
+
+// tokenizeText replaces a text node with the words and the text between them
+func tokenizeText(node *html.Node) {
+	parts := splitWords(node.Data)
+
+	hasWords := false
+	for _, part := range parts {
+		hasWords = hasWords || part.IsWord
+	}
+
+	if !hasWords {
+		return
+	}
+
+	for _, part := range parts {
+		text := &html.Node{Type: html.TextNode, Data: part.Text}
+
+		if !part.IsWord {
+			node.Parent.InsertBefore(text, node)
+			continue
+		}
+
+		span := &html.Node{
+			Type:     html.ElementNode,
+			Data:     "span",
+			DataAtom: atom.Span,
+			Attr:     []html.Attribute{{Key: "class", Val: "obscured"}},
+		}
+		span.AppendChild(text)
+		node.Parent.InsertBefore(span, node)
+	}
+
+	node.Parent.RemoveChild(node)
+}
+
+func isNumeric(word string) bool {
+	for _, r := range word {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+
+	return word != ""
+}
+
+// tokenizeTitle builds the obscured title heading and the list of tokens a
+// player has to guess to win. Punctuation and tokens revealed by the policy are
+// not required, and a trailing parenthetical disambiguator is obscured but not
+// required either.
+func tokenizeTitle(title string, policy RevealPolicy) (heading string, tokens []string) {
+	main, disambiguator := title, ""
+	if match := disambiguatorRegex.FindStringSubmatch(title); match != nil {
+		main, disambiguator = match[1], match[2]
+	}
+
+	tokens = make([]string, 0)
+
+	var sb strings.Builder
+	sb.WriteString(`<h1>`)
+
+	for _, part := range splitWords(main) {
+		if !part.IsWord {
+			sb.WriteString(html.EscapeString(part.Text))
+			continue
+		}
+
+		sb.WriteString(`<span class="obscured">` + html.EscapeString(part.Text) + `</span>`)
+		if !policy.IsRevealed(part.Text) {
+			tokens = append(tokens, Normalize(part.Text))
+		}
+	}
+
+	if disambiguator != "" {
+		sb.WriteString(` <small class="disambiguator">(`)
+		for _, part := range splitWords(disambiguator) {
+			if !part.IsWord {
+				sb.WriteString(html.EscapeString(part.Text))
+				continue
+			}
+
+			sb.WriteString(`<span class="obscured">` + html.EscapeString(part.Text) + `</span>`)
+		}
+		sb.WriteString(`)</small>`)
+	}
+
+	sb.WriteString(`</h1>`)
+
+	return sb.String(), tokens
+}
+
+// validateTitle makes sure every title token can be guessed, so the game can
+// always be won
+func validateTitle(article Article) error {
+	if len(article.TitleTokens) == 0 {
+		return fmt.Errorf("%w: %q has no words to guess", ErrUnwinnableTitle, article.Title)
+	}
+
+	policy := article.RevealPolicy()
+	for _, token := range article.TitleTokens {
+		if policy.IsRevealed(token) {
+			continue
+		}
+
+		if !isGuessableToken(token) {
+			return fmt.Errorf("%w: %q has token %q that can't be typed", ErrUnwinnableTitle, article.Title, token)
+		}
+
+		if _, ok := article.Tokens[token]; !ok {
+			return fmt.Errorf("%w: %q has token %q that is not obscured in the article", ErrUnwinnableTitle, article.Title, token)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,289 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dlclark/regexp2"
+	"golang.org/x/net/html"
+)
+
+// tokenizeBody tokenizes the body of an HTML fragment and renders it back
+func tokenizeBody(t testing.TB, fragment string) string {
+	t.Helper()
+
+	doc, err := html.Parse(strings.NewReader("<html><head></head><body>" + fragment + "</body></html>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenize(doc)
+
+	var body *html.Node
+	var find func(*html.Node)
+	find = func(node *html.Node) {
+		if node.Type == html.ElementNode && node.Data == "body" {
+			body = node
+		}
+		for child := node.FirstChild; child != nil && body == nil; child = child.NextSibling {
+			find(child)
+		}
+	}
+	find(doc)
+
+	var buf bytes.Buffer
+	for child := body.FirstChild; child != nil; child = child.NextSibling {
+		err := html.Render(&buf, child)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return buf.String()
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "words",
+			input: `<p>Mercurio es un planeta.</p>`,
+			want:  `<p><span class="obscured">Mercurio</span> <span class="obscured">es</span> <span class="obscured">un</span> <span class="obscured">planeta</span>.</p>`,
+		},
+		{
+			name:  "accents and digits",
+			input: `<p>Año 1974, señal</p>`,
+			want:  `<p><span class="obscured">Año</span> <span class="obscured">1974</span>, <span class="obscured">señal</span></p>`,
+		},
+		{
+			name:  "entities",
+			input: `<p>Tom &amp; Jerry &lt;3 &quot;dos&quot;</p>`,
+			want:  `<p><span class="obscured">Tom</span> &amp; <span class="obscured">Jerry</span> &lt;<span class="obscured">3</span> &#34;<span class="obscured">dos</span>&#34;</p>`,
+		},
+		{
+			name:  "whitespace",
+			input: "<p>uno  dos\n\ttres </p>",
+			want:  "<p><span class=\"obscured\">uno</span>  <span class=\"obscured\">dos</span>\n\t<span class=\"obscured\">tres</span> </p>",
+		},
+		{
+			name:  "nested elements",
+			input: `<p>El <a href="./Sol">Sol</a> y <b>la Luna</b></p>`,
+			want:  `<p><span class="obscured">El</span> <a href="./Sol"><span class="obscured">Sol</span></a> <span class="obscured">y</span> <b><span class="obscured">la</span> <span class="obscured">Luna</span></b></p>`,
+		},
+		{
+			name:  "attributes",
+			input: `<a href="./Sistema_solar" title="Sistema solar">sistema</a>`,
+			want:  `<a href="./Sistema_solar" title="Sistema solar"><span class="obscured">sistema</span></a>`,
+		},
+		{
+			name:  "script",
+			input: `<p>texto</p><script>var planeta = "Mercurio";</script>`,
+			want:  `<p><span class="obscured">texto</span></p><script>var planeta = "Mercurio";</script>`,
+		},
+		{
+			name:  "style",
+			input: `<style>.infobox { width: 22em }</style><p>texto</p>`,
+			want:  `<style>.infobox { width: 22em }</style><p><span class="obscured">texto</span></p>`,
+		},
+		{
+			name:  "noscript and template",
+			input: `<noscript>sin javascript</noscript><template>plantilla</template>`,
+			want:  `<noscript>sin javascript</noscript><template>plantilla</template>`,
+		},
+		{
+			name:  "svg",
+			input: `<svg><text>órbita</text></svg>`,
+			want:  `<svg><text>órbita</text></svg>`,
+		},
+		{
+			name:  "math",
+			input: `<math><mi>v</mi><mo>=</mo><mi>d</mi></math>`,
+			want:  `<math><mi>v</mi><mo>=</mo><mi>d</mi></math>`,
+		},
+		{
+			name:  "no words",
+			input: `<p> — , </p>`,
+			want:  `<p> — , </p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tokenizeBody(t, tt.input)
+			if got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenizeSkipsHead(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<html><head><title>Mercurio</title></head><body><p>Mercurio</p></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenize(doc)
+
+	var buf bytes.Buffer
+	err = html.Render(&buf, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<html><head><title>Mercurio</title></head><body><p><span class="obscured">Mercurio</span></p></body></html>`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	parts := splitWords("¡Hola, Año-Nuevo 2024!")
+
+	want := []textPart{
+		{Text: "¡"},
+		{Text: "Hola", IsWord: true},
+		{Text: ", "},
+		{Text: "Año", IsWord: true},
+		{Text: "-"},
+		{Text: "Nuevo", IsWord: true},
+		{Text: " "},
+		{Text: "2024", IsWord: true},
+		{Text: "!"},
+	}
+
+	if len(parts) != len(want) {
+		t.Fatalf("got %v, want %v", parts, want)
+	}
+
+	for i := range want {
+		if parts[i] != want[i] {
+			t.Errorf("part %d: got %+v, want %+v", i, parts[i], want[i])
+		}
+	}
+}
+
+// legacyTokenizerRegex is the tokenizer used before text nodes were tokenized,
+// kept to compare their performance
+var legacyTokenizerRegex = regexp2.MustCompile(`(?<=>[^<>]*)(\b[\w]+\b)(?=[^<>]*<)`, regexp2.None)
+
+func loadLargePage(b *testing.B) []byte {
+	b.Helper()
+
+	file, err := os.Open(filepath.Join("testdata", "parsoid_large.html.gz"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	page, err := io.ReadAll(reader)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return page
+}
+
+// BenchmarkTokenize parses a page of about 500KB, tokenizes it and renders it
+// back
+func BenchmarkTokenize(b *testing.B) {
+	page := loadLargePage(b)
+	b.SetBytes(int64(len(page)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		doc, err := html.Parse(bytes.NewReader(page))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		tokenize(doc)
+
+		err = html.Render(io.Discard, doc)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkLegacyTokenize does the same work as BenchmarkTokenize with the old
+// regex tokenizer, which ran over the raw HTML before parsing it
+func BenchmarkLegacyTokenize(b *testing.B) {
+	page := loadLargePage(b)
+	b.SetBytes(int64(len(page)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		tokenized, err := legacyTokenizerRegex.Replace(string(page), `<span class="obscured">$1</span>`, -1, -1)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		doc, err := html.Parse(strings.NewReader(tokenized))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		err = html.Render(io.Discard, doc)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestValidateTitle(t *testing.T) {
+	policy := DefaultRevealPolicy()
+
+	tests := []struct {
+		name       string
+		title      string
+		tokens     []string
+		unwinnable bool
+	}{
+		{name: "winnable", title: "Mercurio (planeta)", tokens: []string{"mercurio"}},
+		{name: "no words", title: "—", unwinnable: true},
+		{name: "not in article", title: "Venus (planeta)", tokens: []string{"mercurio"}, unwinnable: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, titleTokens := tokenizeTitle(tt.title, policy)
+
+			article := Article{
+				Title:       tt.title,
+				TitleTokens: titleTokens,
+				Tokens:      map[string][]int{},
+				Reveal:      &policy,
+			}
+			for i, token := range tt.tokens {
+				article.Tokens[token] = []int{i}
+			}
+
+			err := validateTitle(article)
+			if !tt.unwinnable {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrUnwinnableTitle) || !errors.Is(err, ErrArticleRejected) {
+				t.Errorf("got %v, want an unwinnable title rejection", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// statusError is an unexpected status of a Wikipedia response
+type statusError int
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d %s", int(e), http.StatusText(int(e)))
+}
+
+// checkStatus returns a statusError unless the response is OK
+func checkStatus(res *http.Response) error {
+	if res.StatusCode != http.StatusOK {
+		return statusError(res.StatusCode)
+	}
+
+	return nil
+}
+
+// isTransient reports whether an error may go away by trying again later: the
+// context ending, network failures, and Wikipedia failing or rate limiting.
+// Any other error happens again with the same article.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var status statusError
+	if errors.As(err, &status) {
+		return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
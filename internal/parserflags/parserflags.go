@@ -0,0 +1,190 @@
+// Package parserflags defines the command line flags of the article parser and
+// its scheduler, shared by the binaries that can run them
+package parserflags
+
+import (
+	"github.com/gbandres98/wikidle2/internal/parser"
+	"github.com/gbandres98/wikidle2/internal/store"
+	"github.com/urfave/cli/v2"
+)
+
+// Options holds the values of the flags
+type Options struct {
+	Parser       parser.Config
+	Scheduler    parser.SchedulerConfig
+	AlertWebhook string
+
+	revealWords, cleanupSkip, cleanupSelectors cli.StringSlice
+}
+
+func New() *Options {
+	return &Options{
+		Parser:    parser.DefaultConfig(),
+		Scheduler: parser.DefaultSchedulerConfig(),
+	}
+}
+
+// ParserFlags returns the flags that configure how articles are parsed
+func (o *Options) ParserFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:        "min-word-count",
+			EnvVars:     []string{"WIKIDLE_MIN_WORD_COUNT"},
+			Usage:       "Minimum number of guessable words for an article to be accepted",
+			Value:       o.Parser.Quality.MinWordCount,
+			Destination: &o.Parser.Quality.MinWordCount,
+		},
+		&cli.Float64Flag{
+			Name:        "min-title-in-body",
+			EnvVars:     []string{"WIKIDLE_MIN_TITLE_IN_BODY"},
+			Usage:       "Minimum fraction of title words that must appear in the article body",
+			Value:       o.Parser.Quality.MinTitleTokensInBody,
+			Destination: &o.Parser.Quality.MinTitleTokensInBody,
+		},
+		&cli.Float64Flag{
+			Name:        "max-table-fraction",
+			EnvVars:     []string{"WIKIDLE_MAX_TABLE_FRACTION"},
+			Usage:       "Maximum fraction of the article text that can be inside tables",
+			Value:       o.Parser.Quality.MaxTableFraction,
+			Destination: &o.Parser.Quality.MaxTableFraction,
+		},
+		&cli.IntFlag{
+			Name:        "max-candidates",
+			EnvVars:     []string{"WIKIDLE_MAX_CANDIDATES"},
+			Usage:       "Number of queued articles to try before giving up",
+			Value:       o.Parser.MaxCandidates,
+			Destination: &o.Parser.MaxCandidates,
+		},
+		&cli.BoolFlag{
+			Name:        "reveal-numbers",
+			EnvVars:     []string{"WIKIDLE_REVEAL_NUMBERS"},
+			Usage:       "Show numbers without having to guess them",
+			Value:       o.Parser.Reveal.Numbers,
+			Destination: &o.Parser.Reveal.Numbers,
+		},
+		&cli.BoolFlag{
+			Name:        "reveal-single-letters",
+			EnvVars:     []string{"WIKIDLE_REVEAL_SINGLE_LETTERS"},
+			Usage:       "Show single letter words without having to guess them",
+			Value:       o.Parser.Reveal.SingleLetters,
+			Destination: &o.Parser.Reveal.SingleLetters,
+		},
+		&cli.BoolFlag{
+			Name:        "reveal-stopwords",
+			EnvVars:     []string{"WIKIDLE_REVEAL_STOPWORDS"},
+			Usage:       "Show stopwords without having to guess them",
+			Value:       o.Parser.Reveal.Stopwords,
+			Destination: &o.Parser.Reveal.Stopwords,
+		},
+		&cli.StringSliceFlag{
+			Name:        "reveal-words",
+			EnvVars:     []string{"WIKIDLE_REVEAL_WORDS"},
+			Usage:       "Extra words to show without having to guess them",
+			Destination: &o.revealWords,
+		},
+		&cli.StringFlag{
+			Name:    "image-filter",
+			EnvVars: []string{"WIKIDLE_IMAGE_FILTER"},
+			Usage:   "How to obscure article images (blur, pixelate, silhouette, placeholder)",
+			Value:   string(o.Parser.Images.Filter),
+			Action: func(c *cli.Context, value string) error {
+				filter, err := parser.ParseImageFilter(value)
+				o.Parser.Images.Filter = filter
+				return err
+			},
+		},
+		&cli.IntFlag{
+			Name:        "max-images",
+			EnvVars:     []string{"WIKIDLE_MAX_IMAGES"},
+			Usage:       "Maximum number of images to download per article",
+			Value:       o.Parser.Images.MaxImages,
+			Destination: &o.Parser.Images.MaxImages,
+		},
+		&cli.IntFlag{
+			Name:        "image-levels",
+			EnvVars:     []string{"WIKIDLE_IMAGE_LEVELS"},
+			Usage:       "Number of obscured copies of each image, revealed progressively as the game advances",
+			Value:       o.Parser.Images.Levels,
+			Destination: &o.Parser.Images.Levels,
+		},
+		&cli.StringSliceFlag{
+			Name:        "cleanup-skip",
+			EnvVars:     []string{"WIKIDLE_CLEANUP_SKIP"},
+			Usage:       "Names of the content cleanup stages to skip (title, scripts, media, vcard, authority-control, references, navboxes, coordinates, hatnotes, edit-links)",
+			Destination: &o.cleanupSkip,
+		},
+		&cli.StringSliceFlag{
+			Name:        "cleanup-selectors",
+			EnvVars:     []string{"WIKIDLE_CLEANUP_SELECTORS"},
+			Usage:       "Extra CSS selectors of elements to remove from articles",
+			Destination: &o.cleanupSelectors,
+		},
+	}
+}
+
+// SchedulerFlags returns the flags that configure when the article of the day
+// is parsed and who is alerted when it fails
+func (o *Options) SchedulerFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "cron",
+			EnvVars:     []string{"WIKIDLE_PARSER_CRON"},
+			Usage:       "Cron to run the article parsing job",
+			Value:       o.Scheduler.Cron,
+			Destination: &o.Scheduler.Cron,
+		},
+		&cli.IntFlag{
+			Name:        "retry-attempts",
+			EnvVars:     []string{"WIKIDLE_RETRY_ATTEMPTS"},
+			Usage:       "Attempts of the article parsing job before giving up and alerting",
+			Value:       o.Scheduler.Attempts,
+			Destination: &o.Scheduler.Attempts,
+		},
+		&cli.DurationFlag{
+			Name:        "retry-backoff",
+			EnvVars:     []string{"WIKIDLE_RETRY_BACKOFF"},
+			Usage:       "Delay before retrying the article parsing job, doubled on every retry",
+			Value:       o.Scheduler.Backoff,
+			Destination: &o.Scheduler.Backoff,
+		},
+		&cli.DurationFlag{
+			Name:        "retry-max-backoff",
+			EnvVars:     []string{"WIKIDLE_RETRY_MAX_BACKOFF"},
+			Usage:       "Maximum delay between retries of the article parsing job",
+			Value:       o.Scheduler.MaxBackoff,
+			Destination: &o.Scheduler.MaxBackoff,
+		},
+		&cli.DurationFlag{
+			Name:        "lease-duration",
+			EnvVars:     []string{"WIKIDLE_LEASE_DURATION"},
+			Usage:       "Duration of the lease that lets a single parser instance run the article parsing job",
+			Value:       o.Scheduler.LeaseDuration,
+			Destination: &o.Scheduler.LeaseDuration,
+		},
+		&cli.StringFlag{
+			Name:        "alert-webhook",
+			EnvVars:     []string{"WIKIDLE_ALERT_WEBHOOK"},
+			Usage:       "URL to post alerts to when the article of the day can't be parsed, alerts are only logged if empty",
+			Destination: &o.AlertWebhook,
+		},
+	}
+}
+
+// NewParser builds a parser from the flags
+func (o *Options) NewParser(db *store.DB) *parser.Parser {
+	o.Parser.Reveal.Words = o.revealWords.Value()
+
+	o.Parser.Cleanup = parser.WithoutStages(parser.DefaultCleanupStages(), o.cleanupSkip.Value())
+	if selectors := o.cleanupSelectors.Value(); len(selectors) > 0 {
+		o.Parser.Cleanup = append(o.Parser.Cleanup, parser.SanitizerStage{
+			Name:      "custom",
+			Selectors: selectors,
+		})
+	}
+	return parser.New(db, o.Parser)
+}
+
+// NewScheduler builds a scheduler for a parser built from the flags
+func (o *Options) NewScheduler(db *store.DB) *parser.Scheduler {
+	return parser.NewScheduler(o.NewParser(db), o.Scheduler, parser.NewAlerter(o.AlertWebhook))
+}
@@ -0,0 +1,70 @@
+package parserflags
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/gbandres98/wikidle2/internal/parser"
+	"github.com/urfave/cli/v2"
+)
+
+func TestCleanupFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		// Names of the stages the parser runs, in order
+		stages []string
+		custom []string
+	}{
+		{
+			name:   "default",
+			stages: []string{"title", "scripts", "media", "vcard", "authority-control", "references", "navboxes", "coordinates", "hatnotes", "edit-links"},
+		},
+		{
+			name:   "skip",
+			args:   []string{"--cleanup-skip", "references", "--cleanup-skip", "Hatnotes"},
+			stages: []string{"title", "scripts", "media", "vcard", "authority-control", "navboxes", "coordinates", "edit-links"},
+		},
+		{
+			name:   "custom selectors",
+			args:   []string{"--cleanup-skip", "title", "--cleanup-selectors", ".sister-project", "--cleanup-selectors", "figure"},
+			stages: []string{"scripts", "media", "vcard", "authority-control", "references", "navboxes", "coordinates", "hatnotes", "edit-links", "custom"},
+			custom: []string{".sister-project", "figure"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := New()
+
+			app := &cli.App{
+				Flags:  options.ParserFlags(),
+				Action: func(*cli.Context) error { return nil },
+			}
+
+			err := app.Run(append([]string{"parser"}, tt.args...))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			options.NewParser(nil)
+
+			names := []string{}
+			var custom parser.SanitizerStage
+			for _, stage := range options.Parser.Cleanup {
+				names = append(names, stage.Name)
+				if stage.Name == "custom" {
+					custom = stage
+				}
+			}
+
+			if !slices.Equal(names, tt.stages) {
+				t.Errorf("got stages %v, want %v", names, tt.stages)
+			}
+
+			if !slices.Equal(custom.Selectors, tt.custom) {
+				t.Errorf("got custom selectors %v, want %v", custom.Selectors, tt.custom)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Package server runs the HTTP servers of both binaries with timeouts and a
+// graceful shutdown.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type Timeouts struct {
+	Read  time.Duration
+	Write time.Duration
+	Idle  time.Duration
+	// Time in-flight requests have to finish once the server is stopping
+	Shutdown time.Duration
+}
+
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Read:     10 * time.Second,
+		Write:    30 * time.Second,
+		Idle:     120 * time.Second,
+		Shutdown: 30 * time.Second,
+	}
+}
+
+// Run serves the handler until the context is cancelled, and then waits for
+// the in-flight requests to finish
+func Run(ctx context.Context, addr string, handler http.Handler, timeouts Timeouts) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       timeouts.Read,
+		ReadHeaderTimeout: timeouts.Read,
+		WriteTimeout:      timeouts.Write,
+		IdleTimeout:       timeouts.Idle,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("failed to serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	slog.InfoContext(ctx, "Shutting down server", "addr", addr)
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeouts.Shutdown)
+	defer cancel()
+
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		return fmt.Errorf("failed to shut down server: %w", err)
+	}
+
+	err = <-errs
+	if !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve: %w", err)
+	}
+
+	return nil
+}
@@ -1,6 +1,8 @@
 const onGameWin = () => {
   document.querySelector("#word-input-wrapper").remove();
   document.querySelector("#attempts").remove();
+  document.querySelector("#title-form")?.remove();
+  document.querySelector("#mode")?.remove();
   document.querySelector(".controls").addEventListener("click", toggleModal);
   toggleModal();
 };
@@ -119,3 +121,32 @@ const unhighlightWords = () => {
     e.classList.remove("highlight");
   });
 };
+
+// Game modes
+
+const changeMode = (mode) => {
+  localStorage.setItem("mode", mode);
+  window.location.reload();
+};
+
+// syncMode shows the mode the server is playing, which can't change once the
+// game has started
+const syncMode = (playerData) => {
+  const game = playerData.g || {};
+  const mode = game.m || "normal";
+  const started = (game.s && game.s.length > 0) || !!game.t;
+
+  localStorage.setItem("mode", mode);
+
+  const select = document.getElementById("mode");
+  if (select) {
+    select.value = mode;
+    select.disabled = started;
+  }
+
+  const titleInput = document.getElementById("title-input");
+  if (titleInput) {
+    titleInput.placeholder =
+      mode === "title" ? "¿Sabes el título? Solo un intento" : "¿Sabes el título?";
+  }
+};
@@ -1,10 +1,75 @@
 package static
 
-import "embed"
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"strings"
+)
 
 //go:embed *
 var staticFiles embed.FS
 
+// Content hash of every static file, used to fingerprint their URLs
+var hashes = make(map[string]string)
+
+func init() {
+	err := fs.WalkDir(staticFiles, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		data, err := staticFiles.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(data)
+		hashes[path] = hex.EncodeToString(sum[:])[:16]
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
 func FS() embed.FS {
 	return staticFiles
 }
+
+// Path returns the fingerprinted URL path of a static file, which can be
+// cached indefinitely since it changes along the file
+func Path(name string) string {
+	name = strings.TrimPrefix(name, "/")
+
+	hash, ok := hashes[name]
+	if !ok {
+		return "/" + name
+	}
+
+	return "/" + name + "?v=" + hash
+}
+
+// Handler serves the static files with their content hash as ETag. Requests
+// for the fingerprinted URL of a file are cached indefinitely, the rest are
+// revalidated on every use.
+func Handler() http.Handler {
+	files := http.FileServerFS(staticFiles)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hash, ok := hashes[strings.TrimPrefix(r.URL.Path, "/")]
+		if ok {
+			w.Header().Set("ETag", `"`+hash+`"`)
+
+			if r.URL.Query().Get("v") == hash {
+				w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+			} else {
+				w.Header().Set("Cache-Control", "no-cache")
+			}
+		}
+
+		files.ServeHTTP(w, r)
+	})
+}
@@ -4,6 +4,8 @@ const beforeRequest = (event) => {
   if (gameData) {
     event.detail.formData.set("gameData", gameData);
   }
+
+  event.detail.formData.set("mode", localStorage.getItem("mode") || "normal");
 };
 
 const afterRequest = () => {
@@ -14,4 +16,5 @@ const afterRequest = () => {
     return
 
   localStorage.setItem("gameData", gameDataContainer.textContent);
+  syncMode(JSON.parse(gameDataContainer.textContent));
 };
\ No newline at end of file
@@ -0,0 +1,95 @@
+package store
+
+// The queries in this file differ between drivers, so they can't be generated
+// with the rest. Each one has a statement per driver, picked with statement,
+// and runs on the DB or a Snapshot instead of on Queries. Like every
+// hand-written statement, they start with a "-- query:" comment naming the
+// query in the metrics.
+//
+// In SQLite SERIAL columns aren't filled in, so the rows of article_queue are
+// identified and ordered by their rowid.
+
+import (
+	"context"
+)
+
+// Postgres skips the rows other transactions are popping, so concurrent pops
+// get different articles
+const popQueueArticlePostgres = `-- query: PopQueueArticle
+DELETE FROM article_queue
+WHERE id = (
+    SELECT id FROM article_queue
+    WHERE onDate = $1 OR onDate IS NULL
+    ORDER BY onDate IS NULL, id DESC
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, title, onDate
+`
+
+// SQLite takes the write lock before running the subquery, so the statement's
+// transaction can't race with other pops
+const popQueueArticleSQLite = `-- query: PopQueueArticle
+DELETE FROM article_queue
+WHERE rowid = (
+    SELECT rowid FROM article_queue
+    WHERE onDate = $1 OR onDate IS NULL
+    ORDER BY onDate IS NULL, rowid DESC
+    LIMIT 1
+)
+RETURNING rowid, title, onDate
+`
+
+// Queued articles in the order they were added
+const eachQueueArticlePostgres = `-- query: EachQueueArticle
+SELECT title, onDate FROM article_queue
+ORDER BY id
+`
+
+const eachQueueArticleSQLite = `-- query: EachQueueArticle
+SELECT title, onDate FROM article_queue
+ORDER BY rowid
+`
+
+// statement returns the statement for the driver
+func statement(driver string, postgres string, sqlite string) string {
+	if driver == "sqlite3" {
+		return sqlite
+	}
+
+	return postgres
+}
+
+// PopQueueArticle removes and returns the article queued for the date, or else
+// the newest article queued without a date. Concurrent pops never return the
+// same article.
+func (db *DB) PopQueueArticle(ctx context.Context, date string) (ArticleQueue, error) {
+	query := statement(db.driver, popQueueArticlePostgres, popQueueArticleSQLite)
+
+	row := instrumentedDB{db: db.sqldb}.QueryRowContext(ctx, query, date)
+	var i ArticleQueue
+	err := row.Scan(&i.ID, &i.Title, &i.Ondate)
+	return i, err
+}
+
+// EachQueueArticle calls fn with every queued article, in the order they were
+// added, as they are read
+func (s *Snapshot) EachQueueArticle(ctx context.Context, fn func(ListQueueArticlesRow) error) error {
+	query := statement(s.driver, eachQueueArticlePostgres, eachQueueArticleSQLite)
+
+	rows, err := s.tx.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var i ListQueueArticlesRow
+		if err := rows.Scan(&i.Title, &i.Ondate); err != nil {
+			return err
+		}
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "wikidle_db_query_duration_seconds",
+	Help:    "Duration of the database queries.",
+	Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+}, []string{"query"})
+
+func RegisterMetrics(r prometheus.Registerer) {
+	r.MustRegister(queryDuration)
+}
+
+// queryNameRegex matches the name sqlc adds to every query, or the one given
+// to the hand-written ones
+var queryNameRegex = regexp.MustCompile(`^-- (?:name|query): (\w+)`)
+
+func queryName(query string) string {
+	if match := queryNameRegex.FindStringSubmatch(query); match != nil {
+		return match[1]
+	}
+
+	return "other"
+}
+
+// instrumentedDB measures the duration of every query
+type instrumentedDB struct {
+	db DBTX
+}
+
+func observeQuery(query string, start time.Time) {
+	queryDuration.WithLabelValues(queryName(query)).Observe(time.Since(start).Seconds())
+}
+
+func (i instrumentedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer observeQuery(query, time.Now())
+	return i.db.ExecContext(ctx, query, args...)
+}
+
+func (i instrumentedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return i.db.PrepareContext(ctx, query)
+}
+
+func (i instrumentedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer observeQuery(query, time.Now())
+	return i.db.QueryContext(ctx, query, args...)
+}
+
+func (i instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer observeQuery(query, time.Now())
+	return i.db.QueryRowContext(ctx, query, args...)
+}
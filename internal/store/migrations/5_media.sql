@@ -0,0 +1,8 @@
+-- +goose Up
+CREATE TABLE media (
+    game_id VARCHAR(8) NOT NULL,
+    name TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    data BYTEA NOT NULL,
+    PRIMARY KEY (game_id, name)
+);
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- +goose Up
+ALTER TABLE article ADD COLUMN format INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE article ADD COLUMN token_index BYTEA;
+ALTER TABLE article ADD COLUMN html BYTEA;
\ No newline at end of file
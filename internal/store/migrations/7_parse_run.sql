@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE parse_run (
+    id TEXT NOT NULL PRIMARY KEY,
+    game_id VARCHAR(8) NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    status TEXT NOT NULL,
+    title TEXT,
+    error TEXT
+);
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- +goose Up
+CREATE TABLE lease (
+    name TEXT NOT NULL PRIMARY KEY,
+    holder TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
\ No newline at end of file
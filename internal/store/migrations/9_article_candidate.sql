@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE article_candidate (
+    title TEXT NOT NULL PRIMARY KEY,
+    revision BIGINT NOT NULL,
+    parsed_at TIMESTAMP NOT NULL,
+    status TEXT NOT NULL,
+    quality JSONB NOT NULL,
+    error TEXT
+);
@@ -7,12 +7,16 @@ package store
 import (
 	"database/sql"
 	"encoding/json"
+	"time"
 )
 
 type Article struct {
-	ID      string
-	Content json.RawMessage
-	Title   string
+	ID         string
+	Content    json.RawMessage
+	Title      string
+	Format     int32
+	TokenIndex []byte
+	Html       []byte
 }
 
 type ArticleQueue struct {
@@ -26,3 +30,36 @@ type Game struct {
 	GameID   string
 	GameData json.RawMessage
 }
+
+type Medium struct {
+	GameID      string
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+type ParseRun struct {
+	ID         string
+	GameID     string
+	StartedAt  time.Time
+	FinishedAt sql.NullTime
+	Attempts   int32
+	Status     string
+	Title      sql.NullString
+	Error      sql.NullString
+}
+
+type Lease struct {
+	Name      string
+	Holder    string
+	ExpiresAt time.Time
+}
+
+type ArticleCandidate struct {
+	Title    string
+	Revision int64
+	ParsedAt time.Time
+	Status   string
+	Quality  json.RawMessage
+	Error    sql.NullString
+}
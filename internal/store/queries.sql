@@ -2,34 +2,49 @@
 SELECT * FROM article 
 WHERE id = $1;
 
+-- name: GetArticleIndexByID :one
+SELECT id, content, title, format, token_index FROM article
+WHERE id = $1;
+
+-- name: GetArticleHTMLByID :one
+SELECT format, html FROM article
+WHERE id = $1;
+
+-- name: GetArticleTitles :many
+SELECT title FROM article;
+
+-- name: GetLegacyArticleIDs :many
+SELECT id FROM article
+WHERE format = 0;
+
 -- name: SaveArticle :exec
-INSERT INTO article (id, content, title)
-VALUES ($1, $2, $3)
-ON CONFLICT (id) DO UPDATE SET content = $2, title = $3;
+INSERT INTO article (id, content, title, format, token_index, html)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (id) DO UPDATE SET content = $2, title = $3, format = $4, token_index = $5, html = $6;
 
 -- name: SaveGame :exec
 INSERT INTO game (player_id, game_id, game_data)
 VALUES ($1, $2, $3)
 ON CONFLICT (player_id, game_id) DO UPDATE SET game_data = $3;
 
--- name: GetQueueArticleByDate :one
-SELECT * FROM article_queue
-WHERE onDate = $1;
+-- name: AddArticleToQueue :exec
+INSERT INTO article_queue (title, onDate)
+VALUES ($1, $2);
 
--- name: GetQueueArticle :one
-SELECT * FROM article_queue
-WHERE onDate IS NULL
-ORDER BY id DESC
-LIMIT 1;
+-- name: GetQueueTitles :many
+SELECT title FROM article_queue;
 
+-- name: CountQueueArticles :one
+SELECT COUNT(*) FROM article_queue;
 
--- name: AddArticleToQueue :exec
-INSERT INTO article_queue (title)
-VALUES ($1);
+-- name: CreateParseRun :exec
+INSERT INTO parse_run (id, game_id, started_at, status)
+VALUES ($1, $2, $3, 'running');
 
--- name: DeleteQueueArticle :exec
-DELETE FROM article_queue
-WHERE id = $1;
+-- name: FinishParseRun :exec
+UPDATE parse_run
+SET finished_at = $1, attempts = $2, status = $3, title = $4, error = $5
+WHERE id = $6;
 
 -- name: GetGameCountByGameID :one
 SELECT COUNT(*) FROM game
@@ -40,3 +55,66 @@ select COUNT(*) from game
 where game_id = $1 
 and game_data->>'w' = 'true';
 
+
+-- name: GetGameCountByGameIDAndMode :one
+SELECT COUNT(*) FROM game
+WHERE game_id = $1
+AND COALESCE(game_data->>'m', '') = sqlc.arg(mode);
+
+-- name: GetWinCountByGameIDAndMode :one
+SELECT COUNT(*) FROM game
+WHERE game_id = $1
+AND COALESCE(game_data->>'m', '') = sqlc.arg(mode)
+AND game_data->>'w' = 'true';
+
+-- name: SaveMedia :exec
+INSERT INTO media (game_id, name, content_type, data)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (game_id, name) DO UPDATE SET content_type = $3, data = $4;
+
+-- name: GetMedia :one
+SELECT * FROM media
+WHERE game_id = $1 AND name = $2;
+
+-- name: DeleteMediaByGameID :exec
+DELETE FROM media
+WHERE game_id = $1;
+
+-- name: AcquireLease :execrows
+INSERT INTO lease (name, holder, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+WHERE lease.holder = excluded.holder OR lease.expires_at < sqlc.arg(now);
+
+-- name: ReleaseLease :exec
+DELETE FROM lease
+WHERE name = $1 AND holder = $2;
+
+-- name: ListQueueArticles :many
+SELECT title, onDate FROM article_queue;
+
+-- name: ListScheduledArticles :many
+SELECT title, onDate FROM article_queue
+WHERE onDate IS NOT NULL
+ORDER BY onDate, id;
+
+-- name: ListArticleTitlesBetween :many
+SELECT id, title FROM article
+WHERE id >= sqlc.arg(from_id) AND id <= sqlc.arg(to_id)
+ORDER BY id;
+
+-- name: DeleteScheduledArticles :execrows
+DELETE FROM article_queue
+WHERE onDate = $1;
+
+-- name: DeleteUndatedQueueArticle :exec
+DELETE FROM article_queue
+WHERE title = $1 AND onDate IS NULL;
+
+-- name: SaveArticleCandidate :exec
+INSERT INTO article_candidate (title, revision, parsed_at, status, quality, error)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (title) DO UPDATE SET revision = $2, parsed_at = $3, status = $4, quality = $5, error = $6;
+
+-- name: GetArticleCandidateTitles :many
+SELECT title FROM article_candidate;
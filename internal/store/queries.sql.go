@@ -9,40 +9,254 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"time"
 )
 
+const acquireLease = `-- name: AcquireLease :execrows
+INSERT INTO lease (name, holder, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+WHERE lease.holder = excluded.holder OR lease.expires_at < $4
+`
+
+type AcquireLeaseParams struct {
+	Name      string
+	Holder    string
+	ExpiresAt time.Time
+	Now       time.Time
+}
+
+func (q *Queries) AcquireLease(ctx context.Context, arg AcquireLeaseParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, acquireLease,
+		arg.Name,
+		arg.Holder,
+		arg.ExpiresAt,
+		arg.Now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const addArticleToQueue = `-- name: AddArticleToQueue :exec
-INSERT INTO article_queue (title)
-VALUES ($1)
+INSERT INTO article_queue (title, onDate)
+VALUES ($1, $2)
 `
 
-func (q *Queries) AddArticleToQueue(ctx context.Context, title string) error {
-	_, err := q.db.ExecContext(ctx, addArticleToQueue, title)
+type AddArticleToQueueParams struct {
+	Title  string
+	Ondate sql.NullString
+}
+
+func (q *Queries) AddArticleToQueue(ctx context.Context, arg AddArticleToQueueParams) error {
+	_, err := q.db.ExecContext(ctx, addArticleToQueue, arg.Title, arg.Ondate)
 	return err
 }
 
-const deleteQueueArticle = `-- name: DeleteQueueArticle :exec
+const countQueueArticles = `-- name: CountQueueArticles :one
+SELECT COUNT(*) FROM article_queue
+`
+
+func (q *Queries) CountQueueArticles(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countQueueArticles)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createParseRun = `-- name: CreateParseRun :exec
+INSERT INTO parse_run (id, game_id, started_at, status)
+VALUES ($1, $2, $3, 'running')
+`
+
+type CreateParseRunParams struct {
+	ID        string
+	GameID    string
+	StartedAt time.Time
+}
+
+func (q *Queries) CreateParseRun(ctx context.Context, arg CreateParseRunParams) error {
+	_, err := q.db.ExecContext(ctx, createParseRun, arg.ID, arg.GameID, arg.StartedAt)
+	return err
+}
+
+const deleteMediaByGameID = `-- name: DeleteMediaByGameID :exec
+DELETE FROM media
+WHERE game_id = $1
+`
+
+func (q *Queries) DeleteMediaByGameID(ctx context.Context, gameID string) error {
+	_, err := q.db.ExecContext(ctx, deleteMediaByGameID, gameID)
+	return err
+}
+
+const deleteScheduledArticles = `-- name: DeleteScheduledArticles :execrows
 DELETE FROM article_queue
-WHERE id = $1
+WHERE onDate = $1
+`
+
+func (q *Queries) DeleteScheduledArticles(ctx context.Context, ondate sql.NullString) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteScheduledArticles, ondate)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteUndatedQueueArticle = `-- name: DeleteUndatedQueueArticle :exec
+DELETE FROM article_queue
+WHERE title = $1 AND onDate IS NULL
 `
 
-func (q *Queries) DeleteQueueArticle(ctx context.Context, id int32) error {
-	_, err := q.db.ExecContext(ctx, deleteQueueArticle, id)
+func (q *Queries) DeleteUndatedQueueArticle(ctx context.Context, title string) error {
+	_, err := q.db.ExecContext(ctx, deleteUndatedQueueArticle, title)
+	return err
+}
+
+const finishParseRun = `-- name: FinishParseRun :exec
+UPDATE parse_run
+SET finished_at = $1, attempts = $2, status = $3, title = $4, error = $5
+WHERE id = $6
+`
+
+type FinishParseRunParams struct {
+	FinishedAt sql.NullTime
+	Attempts   int32
+	Status     string
+	Title      sql.NullString
+	Error      sql.NullString
+	ID         string
+}
+
+func (q *Queries) FinishParseRun(ctx context.Context, arg FinishParseRunParams) error {
+	_, err := q.db.ExecContext(ctx, finishParseRun,
+		arg.FinishedAt,
+		arg.Attempts,
+		arg.Status,
+		arg.Title,
+		arg.Error,
+		arg.ID,
+	)
 	return err
 }
 
 const getArticleByID = `-- name: GetArticleByID :one
-SELECT id, content, title FROM article 
+SELECT id, content, title, format, token_index, html FROM article 
 WHERE id = $1
 `
 
 func (q *Queries) GetArticleByID(ctx context.Context, id string) (Article, error) {
 	row := q.db.QueryRowContext(ctx, getArticleByID, id)
 	var i Article
-	err := row.Scan(&i.ID, &i.Content, &i.Title)
+	err := row.Scan(
+		&i.ID,
+		&i.Content,
+		&i.Title,
+		&i.Format,
+		&i.TokenIndex,
+		&i.Html,
+	)
 	return i, err
 }
 
+const getArticleCandidateTitles = `-- name: GetArticleCandidateTitles :many
+SELECT title FROM article_candidate
+`
+
+func (q *Queries) GetArticleCandidateTitles(ctx context.Context) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getArticleCandidateTitles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var title string
+		if err := rows.Scan(&title); err != nil {
+			return nil, err
+		}
+		items = append(items, title)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getArticleHTMLByID = `-- name: GetArticleHTMLByID :one
+SELECT format, html FROM article
+WHERE id = $1
+`
+
+type GetArticleHTMLByIDRow struct {
+	Format int32
+	Html   []byte
+}
+
+func (q *Queries) GetArticleHTMLByID(ctx context.Context, id string) (GetArticleHTMLByIDRow, error) {
+	row := q.db.QueryRowContext(ctx, getArticleHTMLByID, id)
+	var i GetArticleHTMLByIDRow
+	err := row.Scan(&i.Format, &i.Html)
+	return i, err
+}
+
+const getArticleIndexByID = `-- name: GetArticleIndexByID :one
+SELECT id, content, title, format, token_index FROM article
+WHERE id = $1
+`
+
+type GetArticleIndexByIDRow struct {
+	ID         string
+	Content    json.RawMessage
+	Title      string
+	Format     int32
+	TokenIndex []byte
+}
+
+func (q *Queries) GetArticleIndexByID(ctx context.Context, id string) (GetArticleIndexByIDRow, error) {
+	row := q.db.QueryRowContext(ctx, getArticleIndexByID, id)
+	var i GetArticleIndexByIDRow
+	err := row.Scan(
+		&i.ID,
+		&i.Content,
+		&i.Title,
+		&i.Format,
+		&i.TokenIndex,
+	)
+	return i, err
+}
+
+const getArticleTitles = `-- name: GetArticleTitles :many
+SELECT title FROM article
+`
+
+func (q *Queries) GetArticleTitles(ctx context.Context) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getArticleTitles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var title string
+		if err := rows.Scan(&title); err != nil {
+			return nil, err
+		}
+		items = append(items, title)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getGameCountByGameID = `-- name: GetGameCountByGameID :one
 SELECT COUNT(*) FROM game
 WHERE game_id = $1
@@ -55,32 +269,101 @@ func (q *Queries) GetGameCountByGameID(ctx context.Context, gameID string) (int6
 	return count, err
 }
 
-const getQueueArticle = `-- name: GetQueueArticle :one
-SELECT id, title, ondate FROM article_queue
-WHERE onDate IS NULL
-ORDER BY id DESC
-LIMIT 1
+const getGameCountByGameIDAndMode = `-- name: GetGameCountByGameIDAndMode :one
+SELECT COUNT(*) FROM game
+WHERE game_id = $1
+AND COALESCE(game_data->>'m', '') = $2
 `
 
-func (q *Queries) GetQueueArticle(ctx context.Context) (ArticleQueue, error) {
-	row := q.db.QueryRowContext(ctx, getQueueArticle)
-	var i ArticleQueue
-	err := row.Scan(&i.ID, &i.Title, &i.Ondate)
-	return i, err
+type GetGameCountByGameIDAndModeParams struct {
+	GameID string
+	Mode   string
 }
 
-const getQueueArticleByDate = `-- name: GetQueueArticleByDate :one
-SELECT id, title, ondate FROM article_queue
-WHERE onDate = $1
+func (q *Queries) GetGameCountByGameIDAndMode(ctx context.Context, arg GetGameCountByGameIDAndModeParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getGameCountByGameIDAndMode, arg.GameID, arg.Mode)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const getLegacyArticleIDs = `-- name: GetLegacyArticleIDs :many
+SELECT id FROM article
+WHERE format = 0
+`
+
+func (q *Queries) GetLegacyArticleIDs(ctx context.Context) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getLegacyArticleIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMedia = `-- name: GetMedia :one
+SELECT game_id, name, content_type, data FROM media
+WHERE game_id = $1 AND name = $2
 `
 
-func (q *Queries) GetQueueArticleByDate(ctx context.Context, ondate sql.NullString) (ArticleQueue, error) {
-	row := q.db.QueryRowContext(ctx, getQueueArticleByDate, ondate)
-	var i ArticleQueue
-	err := row.Scan(&i.ID, &i.Title, &i.Ondate)
+type GetMediaParams struct {
+	GameID string
+	Name   string
+}
+
+func (q *Queries) GetMedia(ctx context.Context, arg GetMediaParams) (Medium, error) {
+	row := q.db.QueryRowContext(ctx, getMedia, arg.GameID, arg.Name)
+	var i Medium
+	err := row.Scan(
+		&i.GameID,
+		&i.Name,
+		&i.ContentType,
+		&i.Data,
+	)
 	return i, err
 }
 
+const getQueueTitles = `-- name: GetQueueTitles :many
+SELECT title FROM article_queue
+`
+
+func (q *Queries) GetQueueTitles(ctx context.Context) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getQueueTitles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var title string
+		if err := rows.Scan(&title); err != nil {
+			return nil, err
+		}
+		items = append(items, title)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getWinCountByGameID = `-- name: GetWinCountByGameID :one
 select COUNT(*) from game 
 where game_id = $1 
@@ -94,20 +377,196 @@ func (q *Queries) GetWinCountByGameID(ctx context.Context, gameID string) (int64
 	return count, err
 }
 
+const getWinCountByGameIDAndMode = `-- name: GetWinCountByGameIDAndMode :one
+SELECT COUNT(*) FROM game
+WHERE game_id = $1
+AND COALESCE(game_data->>'m', '') = $2
+AND game_data->>'w' = 'true'
+`
+
+type GetWinCountByGameIDAndModeParams struct {
+	GameID string
+	Mode   string
+}
+
+func (q *Queries) GetWinCountByGameIDAndMode(ctx context.Context, arg GetWinCountByGameIDAndModeParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getWinCountByGameIDAndMode, arg.GameID, arg.Mode)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const listArticleTitlesBetween = `-- name: ListArticleTitlesBetween :many
+SELECT id, title FROM article
+WHERE id >= $1 AND id <= $2
+ORDER BY id
+`
+
+type ListArticleTitlesBetweenParams struct {
+	FromID string
+	ToID   string
+}
+
+type ListArticleTitlesBetweenRow struct {
+	ID    string
+	Title string
+}
+
+func (q *Queries) ListArticleTitlesBetween(ctx context.Context, arg ListArticleTitlesBetweenParams) ([]ListArticleTitlesBetweenRow, error) {
+	rows, err := q.db.QueryContext(ctx, listArticleTitlesBetween, arg.FromID, arg.ToID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListArticleTitlesBetweenRow
+	for rows.Next() {
+		var i ListArticleTitlesBetweenRow
+		if err := rows.Scan(&i.ID, &i.Title); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listQueueArticles = `-- name: ListQueueArticles :many
+SELECT title, onDate FROM article_queue
+`
+
+type ListQueueArticlesRow struct {
+	Title  string
+	Ondate sql.NullString
+}
+
+func (q *Queries) ListQueueArticles(ctx context.Context) ([]ListQueueArticlesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listQueueArticles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListQueueArticlesRow
+	for rows.Next() {
+		var i ListQueueArticlesRow
+		if err := rows.Scan(&i.Title, &i.Ondate); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listScheduledArticles = `-- name: ListScheduledArticles :many
+SELECT title, onDate FROM article_queue
+WHERE onDate IS NOT NULL
+ORDER BY onDate, id
+`
+
+type ListScheduledArticlesRow struct {
+	Title  string
+	Ondate sql.NullString
+}
+
+func (q *Queries) ListScheduledArticles(ctx context.Context) ([]ListScheduledArticlesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listScheduledArticles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListScheduledArticlesRow
+	for rows.Next() {
+		var i ListScheduledArticlesRow
+		if err := rows.Scan(&i.Title, &i.Ondate); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const releaseLease = `-- name: ReleaseLease :exec
+DELETE FROM lease
+WHERE name = $1 AND holder = $2
+`
+
+type ReleaseLeaseParams struct {
+	Name   string
+	Holder string
+}
+
+func (q *Queries) ReleaseLease(ctx context.Context, arg ReleaseLeaseParams) error {
+	_, err := q.db.ExecContext(ctx, releaseLease, arg.Name, arg.Holder)
+	return err
+}
+
 const saveArticle = `-- name: SaveArticle :exec
-INSERT INTO article (id, content, title)
-VALUES ($1, $2, $3)
-ON CONFLICT (id) DO UPDATE SET content = $2, title = $3
+INSERT INTO article (id, content, title, format, token_index, html)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (id) DO UPDATE SET content = $2, title = $3, format = $4, token_index = $5, html = $6
 `
 
 type SaveArticleParams struct {
-	ID      string
-	Content json.RawMessage
-	Title   string
+	ID         string
+	Content    json.RawMessage
+	Title      string
+	Format     int32
+	TokenIndex []byte
+	Html       []byte
 }
 
 func (q *Queries) SaveArticle(ctx context.Context, arg SaveArticleParams) error {
-	_, err := q.db.ExecContext(ctx, saveArticle, arg.ID, arg.Content, arg.Title)
+	_, err := q.db.ExecContext(ctx, saveArticle,
+		arg.ID,
+		arg.Content,
+		arg.Title,
+		arg.Format,
+		arg.TokenIndex,
+		arg.Html,
+	)
+	return err
+}
+
+const saveArticleCandidate = `-- name: SaveArticleCandidate :exec
+INSERT INTO article_candidate (title, revision, parsed_at, status, quality, error)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (title) DO UPDATE SET revision = $2, parsed_at = $3, status = $4, quality = $5, error = $6
+`
+
+type SaveArticleCandidateParams struct {
+	Title    string
+	Revision int64
+	ParsedAt time.Time
+	Status   string
+	Quality  json.RawMessage
+	Error    sql.NullString
+}
+
+func (q *Queries) SaveArticleCandidate(ctx context.Context, arg SaveArticleCandidateParams) error {
+	_, err := q.db.ExecContext(ctx, saveArticleCandidate,
+		arg.Title,
+		arg.Revision,
+		arg.ParsedAt,
+		arg.Status,
+		arg.Quality,
+		arg.Error,
+	)
 	return err
 }
 
@@ -127,3 +586,26 @@ func (q *Queries) SaveGame(ctx context.Context, arg SaveGameParams) error {
 	_, err := q.db.ExecContext(ctx, saveGame, arg.PlayerID, arg.GameID, arg.GameData)
 	return err
 }
+
+const saveMedia = `-- name: SaveMedia :exec
+INSERT INTO media (game_id, name, content_type, data)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (game_id, name) DO UPDATE SET content_type = $3, data = $4
+`
+
+type SaveMediaParams struct {
+	GameID      string
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+func (q *Queries) SaveMedia(ctx context.Context, arg SaveMediaParams) error {
+	_, err := q.db.ExecContext(ctx, saveMedia,
+		arg.GameID,
+		arg.Name,
+		arg.ContentType,
+		arg.Data,
+	)
+	return err
+}
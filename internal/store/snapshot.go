@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Snapshot holds the queries of a read-only transaction, along with queries
+// that hand each row to a callback as it's read instead of loading them all.
+// sqlc only generates the latter as lists, so they are written here.
+type Snapshot struct {
+	*Queries
+	tx     instrumentedDB
+	driver string
+}
+
+// InSnapshot runs fn in a read-only transaction, so every query sees the
+// database as it was when the first one ran
+func (db *DB) InSnapshot(ctx context.Context, fn func(s *Snapshot) error) error {
+	tx, err := db.sqldb.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	conn := instrumentedDB{db: tx}
+	err = fn(&Snapshot{Queries: New(conn), tx: conn, driver: db.driver})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const eachArticle = `-- query: EachArticle
+SELECT id, content, title, format, token_index, html FROM article
+ORDER BY id
+`
+
+// EachArticle calls fn with every article as it's read
+func (s *Snapshot) EachArticle(ctx context.Context, fn func(Article) error) error {
+	rows, err := s.tx.QueryContext(ctx, eachArticle)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var i Article
+		if err := rows.Scan(
+			&i.ID,
+			&i.Content,
+			&i.Title,
+			&i.Format,
+			&i.TokenIndex,
+			&i.Html,
+		); err != nil {
+			return err
+		}
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+const eachGame = `-- query: EachGame
+SELECT player_id, game_id, game_data FROM game
+ORDER BY game_id, player_id
+`
+
+// EachGame calls fn with every game as it's read
+func (s *Snapshot) EachGame(ctx context.Context, fn func(Game) error) error {
+	rows, err := s.tx.QueryContext(ctx, eachGame)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var i Game
+		if err := rows.Scan(&i.PlayerID, &i.GameID, &i.GameData); err != nil {
+			return err
+		}
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Media stored without its article is left out
+const eachArticleMedium = `-- query: EachArticleMedium
+SELECT media.game_id, media.name, media.content_type, media.data FROM media
+JOIN article ON article.id = media.game_id
+ORDER BY media.game_id, media.name
+`
+
+// EachArticleMedium calls fn with the media of every article as it's read
+func (s *Snapshot) EachArticleMedium(ctx context.Context, fn func(Medium) error) error {
+	rows, err := s.tx.QueryContext(ctx, eachArticleMedium)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var i Medium
+		if err := rows.Scan(
+			&i.GameID,
+			&i.Name,
+			&i.ContentType,
+			&i.Data,
+		); err != nil {
+			return err
+		}
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -5,7 +5,11 @@ import (
 	"database/sql"
 	"embed"
 	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
 
+	"github.com/gbandres98/wikidle2/internal/health"
 	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/pressly/goose/v3"
@@ -14,23 +18,109 @@ import (
 //go:embed migrations/*.sql
 var migrations embed.FS
 
-func NewDB(ctx context.Context, dbDriver string, dbUrl string, migrate bool) (*Queries, error) {
+// DB holds the queries along with the connection they run on
+type DB struct {
+	*Queries
+	sqldb  *sql.DB
+	driver string
+}
+
+func NewDB(ctx context.Context, dbDriver string, dbUrl string, migrate bool) (*DB, error) {
 	sqldb, err := sql.Open(dbDriver, dbUrl)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open %s database: %w", dbDriver, err)
 	}
 
-	if migrate {
-		goose.SetBaseFS(migrations)
+	goose.SetBaseFS(migrations)
 
-		if err := goose.SetDialect(dbDriver); err != nil {
-			return nil, fmt.Errorf("failed to set goose dialect: %w", err)
-		}
+	if err := goose.SetDialect(dbDriver); err != nil {
+		return nil, fmt.Errorf("failed to set goose dialect: %w", err)
+	}
 
+	if migrate {
 		if err := goose.Up(sqldb, "migrations"); err != nil {
 			return nil, fmt.Errorf("failed to migrate database: %w", err)
 		}
 	}
 
-	return New(sqldb), nil
+	return &DB{
+		Queries: New(instrumentedDB{db: sqldb}),
+		sqldb:   sqldb,
+		driver:  dbDriver,
+	}, nil
+}
+
+// HealthChecks returns the readiness checks of the database
+func (db *DB) HealthChecks() []health.Check {
+	return []health.Check{
+		{Name: "database", Run: db.Ping},
+		{Name: "migrations", Run: db.CheckMigrations},
+	}
+}
+
+func (db *DB) Ping(ctx context.Context) error {
+	return db.sqldb.PingContext(ctx)
+}
+
+func (db *DB) Close() error {
+	return db.sqldb.Close()
+}
+
+// InTx runs fn with queries bound to a transaction, which is committed if fn
+// succeeds and rolled back otherwise
+func (db *DB) InTx(ctx context.Context, fn func(q *Queries) error) error {
+	tx, err := db.sqldb.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = fn(New(instrumentedDB{db: tx}))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CheckMigrations returns an error unless every embedded migration has been
+// applied to the database
+func (db *DB) CheckMigrations(ctx context.Context) error {
+	current, err := goose.GetDBVersionContext(ctx, db.sqldb)
+	if err != nil {
+		return fmt.Errorf("failed to get migration version: %w", err)
+	}
+
+	latest, err := latestMigration()
+	if err != nil {
+		return err
+	}
+
+	if current < latest {
+		return fmt.Errorf("database is at migration %d, expected %d", current, latest)
+	}
+
+	return nil
+}
+
+// latestMigration returns the version of the newest embedded migration
+func latestMigration() (int64, error) {
+	entries, err := fs.ReadDir(migrations, "migrations")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	var latest int64
+	for _, entry := range entries {
+		prefix, _, _ := strings.Cut(entry.Name(), "_")
+
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		latest = max(latest, version)
+	}
+
+	return latest, nil
 }
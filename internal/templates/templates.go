@@ -5,6 +5,8 @@ import (
 	"embed"
 	"html/template"
 	"net/http"
+
+	"github.com/gbandres98/wikidle2/internal/static"
 )
 
 //go:embed *.html
@@ -13,7 +15,9 @@ var templateFiles embed.FS
 var tmpl *template.Template
 
 func init() {
-	tmpl = template.Must(template.ParseFS(templateFiles, "*.html"))
+	tmpl = template.Must(template.New("").Funcs(template.FuncMap{
+		"asset": static.Path,
+	}).ParseFS(templateFiles, "*.html"))
 }
 
 func Execute(w http.ResponseWriter, name string, data interface{}) error {